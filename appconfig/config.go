@@ -2,26 +2,144 @@ package appconfig
 
 import (
 	"io/ioutil"
-	"path/filepath"
+	"os"
+	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 )
 
+// DefaultConfigFile is read when no other configuration file is given.
+const DefaultConfigFile = "config.yml"
+
 type AppConf struct {
-	Kubernetes map[string]string `yaml:"kubernetes"`
-	Logger     string            `yaml:"logger"`
+	Kubernetes        KubernetesConf `yaml:"kubernetes"`
+	AllowedNamespaces []string       `yaml:"allowedNamespaces"`
+	Logger            string         `yaml:"logger"`
+	Logging           LoggingConf    `yaml:"logging"`
+	Defaults          DefaultsConf   `yaml:"defaults"`
+	Auth              AuthConf       `yaml:"auth"`
+	Server            ServerConf     `yaml:"server"`
+}
+
+// LoggingConf refines the logger preset. Empty values keep the preset's
+// level and encoding.
+type LoggingConf struct {
+	Level    string `yaml:"level"`
+	Encoding string `yaml:"encoding"`
+}
+
+// KubernetesConf locates the cluster. An empty Kubeconfig uses the in-cluster
+// configuration, and an empty Namespace the namespace of the pod the server
+// runs in.
+type KubernetesConf struct {
+	Kubeconfig string `yaml:"kubeconfig"`
+	Namespace  string `yaml:"namespace"`
+	// RequestTimeout bounds every Kubernetes API call made by the web API,
+	// except watches. Zero disables the timeout.
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+}
+
+// ServerConf configures the HTTP listener of the web API. Durations are
+// written like 30s or 2m, zero disables the timeout.
+type ServerConf struct {
+	Address             string        `yaml:"address"`
+	ReadTimeout         time.Duration `yaml:"readTimeout"`
+	WriteTimeout        time.Duration `yaml:"writeTimeout"`
+	IdleTimeout         time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes      int           `yaml:"maxHeaderBytes"`
+	ShutdownGracePeriod time.Duration `yaml:"shutdownGracePeriod"`
+	TLS                 TLSConf       `yaml:"tls"`
+}
+
+// TLSConf serves the web API over HTTPS when a certificate and key are set.
+// A client CA additionally requires clients to present a certificate it
+// signed.
+type TLSConf struct {
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	ClientCAFile string `yaml:"clientCAFile"`
+	// OptionalClientCert lets clients without a certificate through, while
+	// still verifying those that present one.
+	OptionalClientCert bool          `yaml:"optionalClientCert"`
+	MinVersion         string        `yaml:"minVersion"`
+	ReloadInterval     time.Duration `yaml:"reloadInterval"`
+}
+
+func defaultServerConf() ServerConf {
+	return ServerConf{
+		Address:             ":8080",
+		ReadTimeout:         30 * time.Second,
+		WriteTimeout:        60 * time.Second,
+		IdleTimeout:         120 * time.Second,
+		MaxHeaderBytes:      1 << 20,
+		ShutdownGracePeriod: 30 * time.Second,
+		TLS: TLSConf{
+			MinVersion:     "1.2",
+			ReloadInterval: 30 * time.Second,
+		},
+	}
+}
+
+// AuthConf controls how callers of the web API are authenticated and
+// authorized.
+type AuthConf struct {
+	Enabled      bool              `yaml:"enabled"`
+	StaticTokens []StaticTokenConf `yaml:"staticTokens"`
+}
+
+// StaticTokenConf maps a bearer token to a user, for local use without a
+// TokenReview capable API server.
+type StaticTokenConf struct {
+	Token  string   `yaml:"token"`
+	User   string   `yaml:"user"`
+	Groups []string `yaml:"groups"`
 }
 
-func GetConf() (*AppConf, error) {
-	var c AppConf
-	filename, _ := filepath.Abs("config.yml")
+// DefaultsConf holds the values applied to MongoDB specs that leave them out.
+type DefaultsConf struct {
+	Version     string `yaml:"version"`
+	Project     string `yaml:"project"`
+	Credentials string `yaml:"credentials"`
+}
+
+// GetConf reads the configuration file, applies the GOKUBE_* environment
+// variable overrides and validates the result. Every invalid setting is
+// reported at once in an Errors.
+func GetConf(filename string) (*AppConf, error) {
+	c := AppConf{
+		Kubernetes: KubernetesConf{RequestTimeout: 30 * time.Second},
+		Server:     defaultServerConf(),
+	}
 	confFile, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	err = yaml.Unmarshal(confFile, &c)
+	err = yaml.UnmarshalStrict(confFile, &c)
 	if err != nil {
 		return nil, err
 	}
+	errs := c.applyEnv(os.LookupEnv)
+	if c.Kubernetes.Namespace == "" {
+		c.Kubernetes.Namespace = podNamespace()
+	}
+	errs = append(errs, c.Validate()...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	return &c, nil
 }
+
+// serviceAccountNamespaceFile is mounted in every pod along with its service
+// account token.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// podNamespace returns the namespace of the pod the server runs in, or an
+// empty string outside of a cluster.
+func podNamespace() string {
+	namespace, err := ioutil.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(namespace))
+}
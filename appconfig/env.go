@@ -0,0 +1,117 @@
+package appconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the name of every environment variable overriding a
+// configuration key.
+const EnvPrefix = "GOKUBE_"
+
+// envOverride sets one configuration key from the value of an environment
+// variable.
+type envOverride struct {
+	name  string
+	apply func(c *AppConf, value string) error
+}
+
+// envOverrides lists the variables overriding the configuration file. Static
+// tokens can only be configured in the file.
+var envOverrides = []envOverride{
+	{"KUBECONFIG", stringEnv(func(c *AppConf) *string { return &c.Kubernetes.Kubeconfig })},
+	{"NAMESPACE", stringEnv(func(c *AppConf) *string { return &c.Kubernetes.Namespace })},
+	{"KUBERNETES_REQUEST_TIMEOUT", durationEnv(func(c *AppConf) *time.Duration { return &c.Kubernetes.RequestTimeout })},
+	{"ALLOWED_NAMESPACES", listEnv(func(c *AppConf) *[]string { return &c.AllowedNamespaces })},
+	{"LOGGER", stringEnv(func(c *AppConf) *string { return &c.Logger })},
+	{"LOG_LEVEL", stringEnv(func(c *AppConf) *string { return &c.Logging.Level })},
+	{"LOG_ENCODING", stringEnv(func(c *AppConf) *string { return &c.Logging.Encoding })},
+	{"DEFAULTS_VERSION", stringEnv(func(c *AppConf) *string { return &c.Defaults.Version })},
+	{"DEFAULTS_PROJECT", stringEnv(func(c *AppConf) *string { return &c.Defaults.Project })},
+	{"DEFAULTS_CREDENTIALS", stringEnv(func(c *AppConf) *string { return &c.Defaults.Credentials })},
+	{"AUTH_ENABLED", boolEnv(func(c *AppConf) *bool { return &c.Auth.Enabled })},
+	{"SERVER_ADDRESS", stringEnv(func(c *AppConf) *string { return &c.Server.Address })},
+	{"SERVER_READ_TIMEOUT", durationEnv(func(c *AppConf) *time.Duration { return &c.Server.ReadTimeout })},
+	{"SERVER_WRITE_TIMEOUT", durationEnv(func(c *AppConf) *time.Duration { return &c.Server.WriteTimeout })},
+	{"SERVER_IDLE_TIMEOUT", durationEnv(func(c *AppConf) *time.Duration { return &c.Server.IdleTimeout })},
+	{"SERVER_MAX_HEADER_BYTES", intEnv(func(c *AppConf) *int { return &c.Server.MaxHeaderBytes })},
+	{"SERVER_SHUTDOWN_GRACE_PERIOD", durationEnv(func(c *AppConf) *time.Duration { return &c.Server.ShutdownGracePeriod })},
+	{"SERVER_TLS_CERT_FILE", stringEnv(func(c *AppConf) *string { return &c.Server.TLS.CertFile })},
+	{"SERVER_TLS_KEY_FILE", stringEnv(func(c *AppConf) *string { return &c.Server.TLS.KeyFile })},
+	{"SERVER_TLS_CLIENT_CA_FILE", stringEnv(func(c *AppConf) *string { return &c.Server.TLS.ClientCAFile })},
+	{"SERVER_TLS_OPTIONAL_CLIENT_CERT", boolEnv(func(c *AppConf) *bool { return &c.Server.TLS.OptionalClientCert })},
+	{"SERVER_TLS_MIN_VERSION", stringEnv(func(c *AppConf) *string { return &c.Server.TLS.MinVersion })},
+	{"SERVER_TLS_RELOAD_INTERVAL", durationEnv(func(c *AppConf) *time.Duration { return &c.Server.TLS.ReloadInterval })},
+}
+
+// applyEnv overrides the keys whose environment variable is set, even to an
+// empty value, and returns the values that could not be parsed.
+func (c *AppConf) applyEnv(lookup func(string) (string, bool)) Errors {
+	var errs Errors
+	for _, override := range envOverrides {
+		value, ok := lookup(EnvPrefix + override.name)
+		if !ok {
+			continue
+		}
+		if err := override.apply(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %s", EnvPrefix, override.name, err.Error()))
+		}
+	}
+	return errs
+}
+
+func stringEnv(field func(*AppConf) *string) func(*AppConf, string) error {
+	return func(c *AppConf, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+// listEnv splits a comma separated value, ignoring blanks.
+func listEnv(field func(*AppConf) *[]string) func(*AppConf, string) error {
+	return func(c *AppConf, value string) error {
+		list := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func boolEnv(field func(*AppConf) *bool) func(*AppConf, string) error {
+	return func(c *AppConf, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func intEnv(field func(*AppConf) *int) func(*AppConf, string) error {
+	return func(c *AppConf, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func durationEnv(field func(*AppConf) *time.Duration) func(*AppConf, string) error {
+	return func(c *AppConf, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package appconfig
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Errors collects every problem found in a configuration.
+type Errors []error
+
+func (errs Errors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d configuration error(s):\n  %s", len(errs), strings.Join(messages, "\n  "))
+}
+
+var (
+	loggerPresets = []string{"DEV", "PROD"}
+	logLevels     = []string{"debug", "info", "warn", "error"}
+	logEncodings  = []string{"console", "json"}
+	tlsVersions   = []string{"1.0", "1.1", "1.2", "1.3"}
+)
+
+// Validate checks the configuration as a whole and returns every invalid
+// key, named by its path in the configuration file.
+func (c *AppConf) Validate() Errors {
+	var errs Errors
+	invalid := func(key string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Kubernetes.Namespace == "" {
+		invalid("kubernetes.namespace", "required when not running in a pod")
+	} else {
+		for _, msg := range validation.IsDNS1123Label(c.Kubernetes.Namespace) {
+			invalid("kubernetes.namespace", "%s", msg)
+		}
+	}
+	for i, namespace := range c.AllowedNamespaces {
+		if namespace == "*" {
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			invalid(fmt.Sprintf("allowedNamespaces[%d]", i), "%s", msg)
+		}
+	}
+	if !contains(loggerPresets, c.Logger) {
+		invalid("logger", "%q is not one of %s", c.Logger, strings.Join(loggerPresets, ", "))
+	}
+	if c.Logging.Level != "" && !contains(logLevels, c.Logging.Level) {
+		invalid("logging.level", "%q is not one of %s", c.Logging.Level, strings.Join(logLevels, ", "))
+	}
+	if c.Logging.Encoding != "" && !contains(logEncodings, c.Logging.Encoding) {
+		invalid("logging.encoding", "%q is not one of %s", c.Logging.Encoding, strings.Join(logEncodings, ", "))
+	}
+
+	if c.Server.Address == "" {
+		invalid("server.address", "required")
+	}
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"kubernetes.requestTimeout", c.Kubernetes.RequestTimeout},
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownGracePeriod", c.Server.ShutdownGracePeriod},
+		{"server.tls.reloadInterval", c.Server.TLS.ReloadInterval},
+	}
+	for _, duration := range durations {
+		if duration.value < 0 {
+			invalid(duration.key, "must not be negative")
+		}
+	}
+	if c.Server.MaxHeaderBytes < 0 {
+		invalid("server.maxHeaderBytes", "must not be negative")
+	}
+
+	tls := c.Server.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		invalid("server.tls", "certFile and keyFile must be set together")
+	}
+	if tls.ClientCAFile != "" && tls.CertFile == "" {
+		invalid("server.tls.clientCAFile", "requires certFile and keyFile")
+	}
+	if tls.MinVersion != "" && !contains(tlsVersions, tls.MinVersion) {
+		invalid("server.tls.minVersion", "%q is not one of %s", tls.MinVersion, strings.Join(tlsVersions, ", "))
+	}
+
+	for i, static := range c.Auth.StaticTokens {
+		if static.Token == "" {
+			invalid(fmt.Sprintf("auth.staticTokens[%d].token", i), "required")
+		}
+		if static.User == "" {
+			invalid(fmt.Sprintf("auth.staticTokens[%d].user", i), "required")
+		}
+	}
+	return errs
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
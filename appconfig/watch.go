@@ -0,0 +1,88 @@
+package appconfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadDelay lets a burst of file events, such as an editor saving or a
+// ConfigMap volume swapping its ..data symlink, settle into one reload.
+const reloadDelay = 500 * time.Millisecond
+
+// Watch re-reads the configuration file whenever it changes on disk or a
+// value arrives on trigger, typically SIGHUP, until ctx is cancelled. Every
+// configuration that passes validation is handed to reloaded; an invalid one
+// is logged and the previous configuration stays in use.
+func Watch(ctx context.Context, filename string, trigger <-chan os.Signal, reloaded func(*AppConf)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	// The directory is watched rather than the file, whose inode changes
+	// when it is replaced instead of written in place.
+	filename, err = filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(filename)); err != nil {
+		return err
+	}
+
+	delay := time.NewTimer(reloadDelay)
+	delay.Stop()
+	reload := func(reason string) {
+		c, err := GetConf(filename)
+		if err != nil {
+			zap.S().Errorf("Ignoring configuration reloaded on %s: %s", reason, err.Error())
+			return
+		}
+		zap.S().Infof("Configuration reloaded on %s from %s", reason, filename)
+		reloaded(c)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-trigger:
+			reload("signal")
+		case event := <-watcher.Events:
+			if event.Name == filename || filepath.Base(event.Name) == "..data" {
+				delay.Reset(reloadDelay)
+			}
+		case <-delay.C:
+			reload("file change")
+		case err := <-watcher.Errors:
+			zap.S().Warnf("Configuration file watcher error: %s", err.Error())
+		}
+	}
+}
+
+// RestartRequired lists the keys changed between old and new that are only
+// read at startup.
+func RestartRequired(old *AppConf, new *AppConf) []string {
+	keys := []string{}
+	changed := func(key string, before interface{}, after interface{}) {
+		if !reflect.DeepEqual(before, after) {
+			keys = append(keys, key)
+		}
+	}
+	changed("kubernetes.kubeconfig", old.Kubernetes.Kubeconfig, new.Kubernetes.Kubeconfig)
+	changed("kubernetes.requestTimeout", old.Kubernetes.RequestTimeout, new.Kubernetes.RequestTimeout)
+	changed("auth.enabled", old.Auth.Enabled, new.Auth.Enabled)
+	changed("server.address", old.Server.Address, new.Server.Address)
+	changed("server.readTimeout", old.Server.ReadTimeout, new.Server.ReadTimeout)
+	changed("server.writeTimeout", old.Server.WriteTimeout, new.Server.WriteTimeout)
+	changed("server.idleTimeout", old.Server.IdleTimeout, new.Server.IdleTimeout)
+	changed("server.maxHeaderBytes", old.Server.MaxHeaderBytes, new.Server.MaxHeaderBytes)
+	changed("server.shutdownGracePeriod", old.Server.ShutdownGracePeriod, new.Server.ShutdownGracePeriod)
+	changed("server.tls", old.Server.TLS, new.Server.TLS)
+	return keys
+}
@@ -0,0 +1,96 @@
+package v1
+
+import (
+	"context"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AuthInterface reviews the tokens and permissions of web API callers.
+type AuthInterface interface {
+	ReviewToken(token string) (*authenticationv1.TokenReviewStatus, error)
+	ReviewAccess(user authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error)
+	ReviewNonResourceAccess(user authenticationv1.UserInfo, attributes authorizationv1.NonResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error)
+
+	ReviewTokenContext(ctx context.Context, token string) (*authenticationv1.TokenReviewStatus, error)
+	ReviewAccessContext(ctx context.Context, user authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error)
+	ReviewNonResourceAccessContext(ctx context.Context, user authenticationv1.UserInfo, attributes authorizationv1.NonResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error)
+}
+
+type authClient struct {
+	client  kubernetes.Interface
+	timeout time.Duration
+}
+
+func (c *authClient) ReviewToken(token string) (*authenticationv1.TokenReviewStatus, error) {
+	return c.ReviewTokenContext(context.Background(), token)
+}
+
+func (c *authClient) ReviewAccess(user authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error) {
+	return c.ReviewAccessContext(context.Background(), user, attributes)
+}
+
+func (c *authClient) ReviewNonResourceAccess(user authenticationv1.UserInfo, attributes authorizationv1.NonResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error) {
+	return c.ReviewNonResourceAccessContext(context.Background(), user, attributes)
+}
+
+// ReviewTokenContext asks the API server to authenticate a bearer token
+// through the TokenReview API.
+func (c *authClient) ReviewTokenContext(ctx context.Context, token string) (*authenticationv1.TokenReviewStatus, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}
+	result := authenticationv1.TokenReview{}
+	err := withTimeout(c.client.AuthenticationV1().RESTClient().Post().Context(ctx), c.timeout).
+		Resource("tokenreviews").
+		Body(review).
+		Do().
+		Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Status, nil
+}
+
+// ReviewAccessContext asks the API server whether the user may perform the
+// action described by the resource attributes, through the
+// SubjectAccessReview API.
+func (c *authClient) ReviewAccessContext(ctx context.Context, user authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error) {
+	return c.reviewAccess(ctx, user, authorizationv1.SubjectAccessReviewSpec{ResourceAttributes: &attributes})
+}
+
+// ReviewNonResourceAccessContext asks the API server whether the user may
+// perform the action described by the non-resource attributes, a verb on a
+// URL path such as the ones RBAC grants through nonResourceURLs.
+func (c *authClient) ReviewNonResourceAccessContext(ctx context.Context, user authenticationv1.UserInfo, attributes authorizationv1.NonResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error) {
+	return c.reviewAccess(ctx, user, authorizationv1.SubjectAccessReviewSpec{NonResourceAttributes: &attributes})
+}
+
+// reviewAccess fills in the user of a SubjectAccessReview spec holding the
+// attributes to check and posts it.
+func (c *authClient) reviewAccess(ctx context.Context, user authenticationv1.UserInfo, spec authorizationv1.SubjectAccessReviewSpec) (*authorizationv1.SubjectAccessReviewStatus, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	spec.User = user.Username
+	spec.Groups = user.Groups
+	spec.UID = user.UID
+	spec.Extra = extra
+	review := &authorizationv1.SubjectAccessReview{Spec: spec}
+	result := authorizationv1.SubjectAccessReview{}
+	err := withTimeout(c.client.AuthorizationV1().RESTClient().Post().Context(ctx), c.timeout).
+		Resource("subjectaccessreviews").
+		Body(review).
+		Do().
+		Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Status, nil
+}
@@ -1,8 +1,12 @@
 package v1
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/10gen/dredd/crdapi/types/v1"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
 	"k8s.io/client-go/kubernetes"
 
 	"go.uber.org/zap"
@@ -16,11 +20,16 @@ import (
 type MongoDBV1Interface interface {
 	MongoDBs(namespace string) MongoDBInterface
 	Core(namespace string) CoreInterface
+	Auth() AuthInterface
+	Cluster() ClusterInterface
 }
 
 type KubeClient struct {
 	restClient rest.Interface
 	coreV1     *kubernetes.Clientset
+	config     *rest.Config
+	// timeout bounds every call but watches, zero leaves them unbounded.
+	timeout time.Duration
 }
 
 func NewForConfig(c *rest.Config) (*KubeClient, error) {
@@ -28,7 +37,12 @@ func NewForConfig(c *rest.Config) (*KubeClient, error) {
 	config.ContentConfig.GroupVersion = &schema.GroupVersion{Group: v1.GroupName, Version: v1.GroupVersion}
 	config.APIPath = "/apis"
 	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: scheme.Codecs}
-	config.UserAgent = rest.DefaultKubernetesUserAgent()
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &metricsRoundTripper{next: &requestIDRoundTripper{next: rt}}
+	})
 
 	restClient, err := rest.RESTClientFor(&config)
 	if err != nil {
@@ -44,19 +58,78 @@ func NewForConfig(c *rest.Config) (*KubeClient, error) {
 	}
 	zap.S().Debugf("Clientset for Core V1 initialised")
 
-	return &KubeClient{restClient: restClient, coreV1: coreV1Client}, nil
+	return &KubeClient{restClient: restClient, coreV1: coreV1Client, config: c}, nil
+}
+
+// Impersonate returns a KubeClient acting on behalf of the given user, so the
+// API server applies that user's RBAC permissions to every call.
+func (c *KubeClient) Impersonate(user authenticationv1.UserInfo) (*KubeClient, error) {
+	config := rest.CopyConfig(c.config)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: user.Username,
+		Groups:   user.Groups,
+		Extra:    map[string][]string{},
+	}
+	for key, value := range user.Extra {
+		config.Impersonate.Extra[key] = value
+	}
+	return c.derive(config)
+}
+
+// WithTimeout returns a KubeClient cancelling every call, except watches,
+// that takes longer than timeout. Clients derived from it keep the timeout.
+// The interface methods ending in Context also stop when their ctx is done;
+// the others run until this timeout, if any.
+func (c *KubeClient) WithTimeout(timeout time.Duration) *KubeClient {
+	client := *c
+	client.timeout = timeout
+	return &client
+}
+
+// derive builds a KubeClient for a modified copy of the configuration.
+func (c *KubeClient) derive(config *rest.Config) (*KubeClient, error) {
+	client, err := NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	client.timeout = c.timeout
+	return client, nil
+}
+
+// withTimeout bounds a call by the client's timeout, when one is set.
+func withTimeout(req *rest.Request, timeout time.Duration) *rest.Request {
+	if timeout <= 0 {
+		return req
+	}
+	return req.Timeout(timeout)
 }
 
 func (c *KubeClient) MongoDBs(namespace string) MongoDBInterface {
 	return &mongoDBClient{
 		restClient: c.restClient,
 		ns:         namespace,
+		timeout:    c.timeout,
 	}
 }
 
 func (c *KubeClient) Core(namespace string) CoreInterface {
 	return &coreClient{
-		client: c.coreV1,
-		ns:     namespace,
+		client:  c.coreV1,
+		ns:      namespace,
+		timeout: c.timeout,
+	}
+}
+
+func (c *KubeClient) Auth() AuthInterface {
+	return &authClient{
+		client:  c.coreV1,
+		timeout: c.timeout,
+	}
+}
+
+func (c *KubeClient) Cluster() ClusterInterface {
+	return &clusterClient{
+		client:  c.coreV1,
+		timeout: c.timeout,
 	}
 }
@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/10gen/dredd/crdapi/types/v1"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterInterface checks the cluster the server talks to.
+type ClusterInterface interface {
+	ServerVersion() (*version.Info, error)
+	MongoDBResource() (*metav1.APIResource, error)
+	GetNamespace(name string) (*apiv1.Namespace, error)
+
+	ServerVersionContext(ctx context.Context) (*version.Info, error)
+	MongoDBResourceContext(ctx context.Context) (*metav1.APIResource, error)
+	GetNamespaceContext(ctx context.Context, name string) (*apiv1.Namespace, error)
+}
+
+type clusterClient struct {
+	client  kubernetes.Interface
+	timeout time.Duration
+}
+
+func (c *clusterClient) ServerVersion() (*version.Info, error) {
+	return c.ServerVersionContext(context.Background())
+}
+
+func (c *clusterClient) MongoDBResource() (*metav1.APIResource, error) {
+	return c.MongoDBResourceContext(context.Background())
+}
+
+func (c *clusterClient) GetNamespace(name string) (*apiv1.Namespace, error) {
+	return c.GetNamespaceContext(context.Background(), name)
+}
+
+// ServerVersionContext reads the version of the API server, like the typed
+// discovery client, which takes no context.
+func (c *clusterClient) ServerVersionContext(ctx context.Context) (*version.Info, error) {
+	body, err := withTimeout(c.client.Discovery().RESTClient().Get().Context(ctx), c.timeout).
+		AbsPath("/version").
+		Do().
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	info := version.Info{}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("unable to parse the server version: %s", err.Error())
+	}
+	return &info, nil
+}
+
+// MongoDBResourceContext looks up the MongoDB resource through discovery,
+// which fails when the mongodb.com/v1 CRD isn't registered.
+func (c *clusterClient) MongoDBResourceContext(ctx context.Context) (*metav1.APIResource, error) {
+	resources := metav1.APIResourceList{}
+	err := withTimeout(c.client.Discovery().RESTClient().Get().Context(ctx), c.timeout).
+		AbsPath("/apis", v1.SchemeGroupVersion.String()).
+		Do().
+		Into(&resources)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resources.APIResources {
+		if resources.APIResources[i].Kind == "MongoDB" {
+			return &resources.APIResources[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the MongoDB kind is not served by %s", v1.SchemeGroupVersion.String())
+}
+
+func (c *clusterClient) GetNamespaceContext(ctx context.Context, name string) (*apiv1.Namespace, error) {
+	result := apiv1.Namespace{}
+	err := withTimeout(c.client.CoreV1().RESTClient().Get().Context(ctx), c.timeout).
+		Resource("namespaces").
+		Name(name).
+		Do().
+		Into(&result)
+	return &result, err
+}
@@ -1,108 +1,329 @@
 package v1
 
 import (
-	"encoding/base64"
+	"context"
+	"time"
 
-	"go.uber.org/zap"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 )
 
+// CoreInterface manages the ConfigMaps and Secrets of a namespace.
 type CoreInterface interface {
 	CreateConfigMap(projectName string, projectId string, baseUrl string) (*apiv1.ConfigMap, error)
 	CreateSecret(projectName string, apiUser string, apiKey string) (*apiv1.Secret, error)
+	CreateGenericConfigMap(spec ConfigMapSpec) (*apiv1.ConfigMap, error)
+	UpdateGenericConfigMap(spec ConfigMapSpec) (*apiv1.ConfigMap, error)
+	CreateGenericSecret(spec SecretSpec) (*apiv1.Secret, error)
+	UpdateGenericSecret(spec SecretSpec) (*apiv1.Secret, error)
+	RotateCredentials(secretName string, apiUser string, apiKey string, resourceVersion string) (*apiv1.Secret, error)
 	DeleteConfigMap(projectName string) error
 	DeleteSecret(secretName string) error
 	GetConfigMaps() (*apiv1.ConfigMapList, error)
 	GetConfigMap(projectName string) (*apiv1.ConfigMap, error)
 	GetSecret(secretName string) (*apiv1.Secret, error)
 	GetSecrets() (*apiv1.SecretList, error)
+
+	CreateConfigMapContext(ctx context.Context, projectName string, projectId string, baseUrl string) (*apiv1.ConfigMap, error)
+	CreateSecretContext(ctx context.Context, projectName string, apiUser string, apiKey string) (*apiv1.Secret, error)
+	CreateGenericConfigMapContext(ctx context.Context, spec ConfigMapSpec) (*apiv1.ConfigMap, error)
+	UpdateGenericConfigMapContext(ctx context.Context, spec ConfigMapSpec) (*apiv1.ConfigMap, error)
+	CreateGenericSecretContext(ctx context.Context, spec SecretSpec) (*apiv1.Secret, error)
+	UpdateGenericSecretContext(ctx context.Context, spec SecretSpec) (*apiv1.Secret, error)
+	RotateCredentialsContext(ctx context.Context, secretName string, apiUser string, apiKey string, resourceVersion string) (*apiv1.Secret, error)
+	DeleteConfigMapContext(ctx context.Context, projectName string) error
+	DeleteSecretContext(ctx context.Context, secretName string) error
+	GetConfigMapsContext(ctx context.Context, opts metav1.ListOptions) (*apiv1.ConfigMapList, error)
+	GetConfigMapContext(ctx context.Context, projectName string) (*apiv1.ConfigMap, error)
+	GetSecretContext(ctx context.Context, secretName string) (*apiv1.Secret, error)
+	GetSecretsContext(ctx context.Context, opts metav1.ListOptions) (*apiv1.SecretList, error)
+}
+
+// CredentialsRotatedAnnotation records when the Ops Manager API credentials
+// in a Secret were last rotated.
+const CredentialsRotatedAnnotation = "gokube.mongodb.com/credentials-rotated-at"
+
+// ConfigMapSpec describes a ConfigMap with arbitrary content. ResourceVersion
+// is only used on update, where it makes the API server reject the change if
+// the ConfigMap was modified in the meantime.
+type ConfigMapSpec struct {
+	Name            string
+	Labels          map[string]string
+	Annotations     map[string]string
+	Data            map[string]string
+	ResourceVersion string
+}
+
+// SecretSpec describes a Secret with arbitrary content and type, such as
+// kubernetes.io/tls. An empty Type creates an Opaque Secret and, on update,
+// keeps the type of the existing Secret, which can't be changed.
+type SecretSpec struct {
+	Name            string
+	Type            apiv1.SecretType
+	Labels          map[string]string
+	Annotations     map[string]string
+	Data            map[string][]byte
+	StringData      map[string]string
+	ResourceVersion string
 }
 
 type coreClient struct {
-	client kubernetes.Interface
-	ns     string
+	client  kubernetes.Interface
+	ns      string
+	timeout time.Duration
+}
+
+// request starts a call on a core resource of the namespace, cancelled with
+// ctx or after the call timeout. The typed clientset takes no context, so the
+// calls go through its REST client.
+func (c *coreClient) request(ctx context.Context, req *rest.Request, resource string) *rest.Request {
+	return withTimeout(req.Context(ctx), c.timeout).Namespace(c.ns).Resource(resource)
+}
+
+func (c *coreClient) restClient() rest.Interface {
+	return c.client.CoreV1().RESTClient()
 }
 
 func (c *coreClient) DeleteConfigMap(projectName string) error {
-	err := c.client.CoreV1().ConfigMaps(c.ns).Delete(projectName, &metav1.DeleteOptions{})
-	return err
+	return c.DeleteConfigMapContext(context.Background(), projectName)
 }
 
 func (c *coreClient) GetConfigMap(projectName string) (*apiv1.ConfigMap, error) {
-	cfgMap, err := c.client.CoreV1().ConfigMaps(c.ns).Get(projectName, metav1.GetOptions{})
-	return cfgMap, err
+	return c.GetConfigMapContext(context.Background(), projectName)
 }
 
 func (c *coreClient) GetConfigMaps() (*apiv1.ConfigMapList, error) {
-	cfgMaps, err := c.client.CoreV1().ConfigMaps(c.ns).List(metav1.ListOptions{})
-	return cfgMaps, err
+	return c.GetConfigMapsContext(context.Background(), metav1.ListOptions{})
 }
 
 func (c *coreClient) CreateConfigMap(projectName string, orgId string, baseUrl string) (*apiv1.ConfigMap, error) {
-	configMap := &apiv1.ConfigMap{
+	return c.CreateConfigMapContext(context.Background(), projectName, orgId, baseUrl)
+}
+
+func (c *coreClient) CreateGenericConfigMap(spec ConfigMapSpec) (*apiv1.ConfigMap, error) {
+	return c.CreateGenericConfigMapContext(context.Background(), spec)
+}
+
+func (c *coreClient) UpdateGenericConfigMap(spec ConfigMapSpec) (*apiv1.ConfigMap, error) {
+	return c.UpdateGenericConfigMapContext(context.Background(), spec)
+}
+
+func (c *coreClient) DeleteSecret(secretName string) error {
+	return c.DeleteSecretContext(context.Background(), secretName)
+}
+
+func (c *coreClient) CreateSecret(secretName string, apiUser string, apiKey string) (*apiv1.Secret, error) {
+	return c.CreateSecretContext(context.Background(), secretName, apiUser, apiKey)
+}
+
+func (c *coreClient) CreateGenericSecret(spec SecretSpec) (*apiv1.Secret, error) {
+	return c.CreateGenericSecretContext(context.Background(), spec)
+}
+
+func (c *coreClient) UpdateGenericSecret(spec SecretSpec) (*apiv1.Secret, error) {
+	return c.UpdateGenericSecretContext(context.Background(), spec)
+}
+
+func (c *coreClient) RotateCredentials(secretName string, apiUser string, apiKey string, resourceVersion string) (*apiv1.Secret, error) {
+	return c.RotateCredentialsContext(context.Background(), secretName, apiUser, apiKey, resourceVersion)
+}
+
+func (c *coreClient) GetSecret(secretName string) (*apiv1.Secret, error) {
+	return c.GetSecretContext(context.Background(), secretName)
+}
+
+func (c *coreClient) GetSecrets() (*apiv1.SecretList, error) {
+	return c.GetSecretsContext(context.Background(), metav1.ListOptions{})
+}
+
+func (c *coreClient) DeleteConfigMapContext(ctx context.Context, projectName string) error {
+	return c.request(ctx, c.restClient().Delete(), "configmaps").
+		Name(projectName).
+		Body(&metav1.DeleteOptions{}).
+		Do().
+		Error()
+}
+
+func (c *coreClient) GetConfigMapContext(ctx context.Context, projectName string) (*apiv1.ConfigMap, error) {
+	result := apiv1.ConfigMap{}
+	err := c.request(ctx, c.restClient().Get(), "configmaps").
+		Name(projectName).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+func (c *coreClient) GetConfigMapsContext(ctx context.Context, opts metav1.ListOptions) (*apiv1.ConfigMapList, error) {
+	result := apiv1.ConfigMapList{}
+	err := c.request(ctx, c.restClient().Get(), "configmaps").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+func (c *coreClient) CreateConfigMapContext(ctx context.Context, projectName string, orgId string, baseUrl string) (*apiv1.ConfigMap, error) {
+	return c.CreateGenericConfigMapContext(ctx, ConfigMapSpec{
+		Name: projectName,
+		Data: map[string]string{
+			"projectName": projectName,
+			"orgId":       orgId,
+			"baseUrl":     baseUrl,
+		},
+	})
+}
+
+func (c *coreClient) CreateGenericConfigMapContext(ctx context.Context, spec ConfigMapSpec) (*apiv1.ConfigMap, error) {
+	spec.ResourceVersion = ""
+	result := apiv1.ConfigMap{}
+	err := c.request(ctx, c.restClient().Post(), "configmaps").
+		Body(c.configMapFor(spec)).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+func (c *coreClient) UpdateGenericConfigMapContext(ctx context.Context, spec ConfigMapSpec) (*apiv1.ConfigMap, error) {
+	result := apiv1.ConfigMap{}
+	err := c.request(ctx, c.restClient().Put(), "configmaps").
+		Name(spec.Name).
+		Body(c.configMapFor(spec)).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+func (c *coreClient) configMapFor(spec ConfigMapSpec) *apiv1.ConfigMap {
+	return &apiv1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ConfigMap",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      projectName,
-			Namespace: c.ns,
+			Name:            spec.Name,
+			Namespace:       c.ns,
+			Labels:          spec.Labels,
+			Annotations:     spec.Annotations,
+			ResourceVersion: spec.ResourceVersion,
 		},
-		Data: map[string]string{
-			"projectName": projectName,
-			"orgId":       orgId,
-			"baseUrl":     baseUrl,
+		Data: spec.Data,
+	}
+}
+
+func (c *coreClient) DeleteSecretContext(ctx context.Context, secretName string) error {
+	return c.request(ctx, c.restClient().Delete(), "secrets").
+		Name(secretName).
+		Body(&metav1.DeleteOptions{}).
+		Do().
+		Error()
+}
+
+func (c *coreClient) CreateSecretContext(ctx context.Context, secretName string, apiUser string, apiKey string) (*apiv1.Secret, error) {
+	return c.CreateGenericSecretContext(ctx, SecretSpec{
+		Name: secretName,
+		Type: apiv1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"user":         []byte(apiUser),
+			"publicApiKey": []byte(apiKey),
 		},
+	})
+}
+
+func (c *coreClient) CreateGenericSecretContext(ctx context.Context, spec SecretSpec) (*apiv1.Secret, error) {
+	spec.ResourceVersion = ""
+	result := apiv1.Secret{}
+	err := c.request(ctx, c.restClient().Post(), "secrets").
+		Body(c.secretFor(spec)).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+func (c *coreClient) UpdateGenericSecretContext(ctx context.Context, spec SecretSpec) (*apiv1.Secret, error) {
+	if spec.Type == "" {
+		existing, err := c.GetSecretContext(ctx, spec.Name)
+		if err != nil {
+			return nil, err
+		}
+		spec.Type = existing.Type
 	}
-	result, err := c.client.CoreV1().ConfigMaps(c.ns).Create(configMap)
-	return result, err
+	return c.updateSecret(ctx, c.secretFor(spec))
 }
 
-func (c *coreClient) DeleteSecret(secretName string) error {
-	err := c.client.CoreV1().Secrets(c.ns).Delete(secretName, &metav1.DeleteOptions{})
-	return err
+func (c *coreClient) updateSecret(ctx context.Context, secret *apiv1.Secret) (*apiv1.Secret, error) {
+	result := apiv1.Secret{}
+	err := c.request(ctx, c.restClient().Put(), "secrets").
+		Name(secret.Name).
+		Body(secret).
+		Do().
+		Into(&result)
+	return &result, err
 }
 
-func (c *coreClient) CreateSecret(secretName string, apiUser string, apiKey string) (*apiv1.Secret, error) {
-	encodedUser := base64.StdEncoding.EncodeToString([]byte(apiUser))
-	encodedKey := base64.StdEncoding.EncodeToString([]byte(apiKey))
-	decodedUser, err := base64.StdEncoding.DecodeString(encodedUser)
+// RotateCredentialsContext replaces the Ops Manager API user and key in an
+// existing credentials Secret in a single update, keeping any other key. The
+// update is conditional on resourceVersion, or on the version read here when
+// it is empty, so a concurrent change makes it fail with a Conflict error.
+func (c *coreClient) RotateCredentialsContext(ctx context.Context, secretName string, apiUser string, apiKey string, resourceVersion string) (*apiv1.Secret, error) {
+	secret, err := c.GetSecretContext(ctx, secretName)
 	if err != nil {
-		zap.S().Debug("Decoding String for user failed!")
 		return nil, err
 	}
-	decodedKey, err := base64.StdEncoding.DecodeString(encodedKey)
-	if err != nil {
-		zap.S().Debug("Decoding String for key failed!")
-		return nil, err
+	if resourceVersion != "" {
+		secret.ResourceVersion = resourceVersion
 	}
-	secret := &apiv1.Secret{
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data["user"] = []byte(apiUser)
+	secret.Data["publicApiKey"] = []byte(apiKey)
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[CredentialsRotatedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	return c.updateSecret(ctx, secret)
+}
+
+func (c *coreClient) secretFor(spec SecretSpec) *apiv1.Secret {
+	secretType := spec.Type
+	if secretType == "" {
+		secretType = apiv1.SecretTypeOpaque
+	}
+	return &apiv1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Secret",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
-			Namespace: c.ns,
-		},
-		Type: "from-literal",
-		Data: map[string][]byte{
-			"user":         decodedUser,
-			"publicApiKey": decodedKey,
+			Name:            spec.Name,
+			Namespace:       c.ns,
+			Labels:          spec.Labels,
+			Annotations:     spec.Annotations,
+			ResourceVersion: spec.ResourceVersion,
 		},
+		Type:       secretType,
+		Data:       spec.Data,
+		StringData: spec.StringData,
 	}
-	result, err := c.client.CoreV1().Secrets(c.ns).Create(secret)
-	return result, err
 }
 
-func (c *coreClient) GetSecret(secretName string) (*apiv1.Secret, error) {
-	secret, err := c.client.CoreV1().Secrets(c.ns).Get(secretName, metav1.GetOptions{})
-	return secret, err
+func (c *coreClient) GetSecretContext(ctx context.Context, secretName string) (*apiv1.Secret, error) {
+	result := apiv1.Secret{}
+	err := c.request(ctx, c.restClient().Get(), "secrets").
+		Name(secretName).
+		Do().
+		Into(&result)
+	return &result, err
 }
 
-func (c *coreClient) GetSecrets() (*apiv1.SecretList, error) {
-	secrets, err := c.client.CoreV1().Secrets(c.ns).List(metav1.ListOptions{})
-	return secrets, err
+func (c *coreClient) GetSecretsContext(ctx context.Context, opts metav1.ListOptions) (*apiv1.SecretList, error) {
+	result := apiv1.SecretList{}
+	err := c.request(ctx, c.restClient().Get(), "secrets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(&result)
+	return &result, err
 }
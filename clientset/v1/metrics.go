@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/10gen/dredd/metrics"
+)
+
+// metricsRoundTripper records the latency and the failures of every call
+// made to the Kubernetes API.
+type metricsRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (rt *metricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	verb, resource := requestAttributes(req)
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(req)
+	metrics.KubeClientRequestDuration.WithLabelValues(verb, resource).Observe(time.Since(start).Seconds())
+	switch {
+	case err != nil:
+		metrics.KubeClientErrorsTotal.WithLabelValues(verb, resource, "").Inc()
+	case resp.StatusCode >= http.StatusBadRequest:
+		metrics.KubeClientErrorsTotal.WithLabelValues(verb, resource, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	return resp, err
+}
+
+// requestAttributes derives the Kubernetes verb and resource from a request
+// to a path such as /apis/{group}/{version}/namespaces/{ns}/{resource}/{name}.
+// A path ending at the group version is reported as the "discovery" resource.
+func requestAttributes(req *http.Request) (string, string) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return strings.ToLower(req.Method), "unknown"
+	}
+	if len(parts) == 0 {
+		// The group version itself lists its resources, as when checking
+		// that a CRD is installed.
+		return strings.ToLower(req.Method), "discovery"
+	}
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	resource := parts[0]
+	if len(parts) >= 3 {
+		resource += "/" + parts[2]
+	}
+	named := len(parts) >= 2
+
+	switch req.Method {
+	case http.MethodGet:
+		if req.URL.Query().Get("watch") == "true" {
+			return "watch", resource
+		}
+		if named {
+			return "get", resource
+		}
+		return "list", resource
+	case http.MethodPost:
+		return "create", resource
+	case http.MethodPut:
+		return "update", resource
+	case http.MethodPatch:
+		return "patch", resource
+	case http.MethodDelete:
+		return "delete", resource
+	}
+	return strings.ToLower(req.Method), resource
+}
@@ -1,34 +1,118 @@
 package v1
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/10gen/dredd/crdapi/types/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 )
 
+// MongoDBInterface manages the MongoDB resources of a namespace.
 type MongoDBInterface interface {
 	List(opts metav1.ListOptions) (*v1.MongoDBList, error)
 	Get(name string, options metav1.GetOptions) (*v1.MongoDB, error)
+	GetRaw(name string) ([]byte, error)
 	Create(*v1.MongoDB) (*v1.MongoDB, error)
+	Update(*v1.MongoDB) (*v1.MongoDB, error)
+	Patch(name string, pt types.PatchType, data []byte) (*v1.MongoDB, error)
+	GetStatus(name string) (*v1.MongoDB, error)
+	UpdateStatus(*v1.MongoDB) (*v1.MongoDB, error)
 	Delete(name string) error
 	Watch(opts metav1.ListOptions) (watch.Interface, error)
-	// ...
+	WaitForPhase(name string, phase v1.Phase, timeout time.Duration) (*v1.MongoDB, error)
+
+	ListContext(ctx context.Context, opts metav1.ListOptions) (*v1.MongoDBList, error)
+	GetContext(ctx context.Context, name string, options metav1.GetOptions) (*v1.MongoDB, error)
+	GetRawContext(ctx context.Context, name string) ([]byte, error)
+	CreateContext(ctx context.Context, mongodb *v1.MongoDB) (*v1.MongoDB, error)
+	UpdateContext(ctx context.Context, mongodb *v1.MongoDB) (*v1.MongoDB, error)
+	PatchContext(ctx context.Context, name string, pt types.PatchType, data []byte) (*v1.MongoDB, error)
+	GetStatusContext(ctx context.Context, name string) (*v1.MongoDB, error)
+	UpdateStatusContext(ctx context.Context, mongodb *v1.MongoDB) (*v1.MongoDB, error)
+	DeleteContext(ctx context.Context, name string) error
+	WatchContext(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	WaitForPhaseContext(ctx context.Context, name string, phase v1.Phase, timeout time.Duration) (*v1.MongoDB, error)
 }
 
+var (
+	// ErrWaitTimeout is returned by WaitForPhase when the resource didn't
+	// reach the requested phase in time.
+	ErrWaitTimeout = errors.New("timed out waiting for the MongoDB resource to reach the requested phase")
+	// ErrPhaseFailed is returned by WaitForPhase when the resource moved to
+	// the Failed phase while waiting for a different one.
+	ErrPhaseFailed = errors.New("the MongoDB resource moved to the Failed phase")
+	// ErrResourceDeleted is returned by WaitForPhase when the resource was
+	// deleted while waiting.
+	ErrResourceDeleted = errors.New("the MongoDB resource was deleted")
+)
+
 type mongoDBClient struct {
 	restClient rest.Interface
 	ns         string
+	timeout    time.Duration
+}
+
+// request starts a call on the MongoDB resources of the namespace, cancelled
+// with ctx or after the call timeout.
+func (c *mongoDBClient) request(ctx context.Context, req *rest.Request) *rest.Request {
+	return withTimeout(req.Context(ctx), c.timeout).Namespace(c.ns).Resource("mongodb")
 }
 
 func (c *mongoDBClient) List(opts metav1.ListOptions) (*v1.MongoDBList, error) {
+	return c.ListContext(context.Background(), opts)
+}
+
+func (c *mongoDBClient) Get(name string, opts metav1.GetOptions) (*v1.MongoDB, error) {
+	return c.GetContext(context.Background(), name, opts)
+}
+
+func (c *mongoDBClient) GetRaw(name string) ([]byte, error) {
+	return c.GetRawContext(context.Background(), name)
+}
+
+func (c *mongoDBClient) Create(mongodb *v1.MongoDB) (*v1.MongoDB, error) {
+	return c.CreateContext(context.Background(), mongodb)
+}
+
+func (c *mongoDBClient) Update(mongodb *v1.MongoDB) (*v1.MongoDB, error) {
+	return c.UpdateContext(context.Background(), mongodb)
+}
+
+func (c *mongoDBClient) Patch(name string, pt types.PatchType, data []byte) (*v1.MongoDB, error) {
+	return c.PatchContext(context.Background(), name, pt, data)
+}
+
+func (c *mongoDBClient) GetStatus(name string) (*v1.MongoDB, error) {
+	return c.GetStatusContext(context.Background(), name)
+}
+
+func (c *mongoDBClient) UpdateStatus(mongodb *v1.MongoDB) (*v1.MongoDB, error) {
+	return c.UpdateStatusContext(context.Background(), mongodb)
+}
+
+func (c *mongoDBClient) Delete(name string) error {
+	return c.DeleteContext(context.Background(), name)
+}
+
+func (c *mongoDBClient) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	return c.WatchContext(context.Background(), opts)
+}
+
+func (c *mongoDBClient) WaitForPhase(name string, phase v1.Phase, timeout time.Duration) (*v1.MongoDB, error) {
+	return c.WaitForPhaseContext(context.Background(), name, phase, timeout)
+}
+
+func (c *mongoDBClient) ListContext(ctx context.Context, opts metav1.ListOptions) (*v1.MongoDBList, error) {
 	result := v1.MongoDBList{}
-	err := c.restClient.
-		Get().
-		Namespace(c.ns).
-		Resource("mongodb").
+	err := c.request(ctx, c.restClient.Get()).
 		VersionedParams(&opts, scheme.ParameterCodec).
 		Do().
 		Into(&result)
@@ -36,12 +120,9 @@ func (c *mongoDBClient) List(opts metav1.ListOptions) (*v1.MongoDBList, error) {
 	return &result, err
 }
 
-func (c *mongoDBClient) Get(name string, opts metav1.GetOptions) (*v1.MongoDB, error) {
+func (c *mongoDBClient) GetContext(ctx context.Context, name string, opts metav1.GetOptions) (*v1.MongoDB, error) {
 	result := v1.MongoDB{}
-	err := c.restClient.
-		Get().
-		Namespace(c.ns).
-		Resource("mongodb").
+	err := c.request(ctx, c.restClient.Get()).
 		Name(name).
 		VersionedParams(&opts, scheme.ParameterCodec).
 		Do().
@@ -50,33 +131,164 @@ func (c *mongoDBClient) Get(name string, opts metav1.GetOptions) (*v1.MongoDB, e
 	return &result, err
 }
 
-func (c *mongoDBClient) Create(mongodb *v1.MongoDB) (*v1.MongoDB, error) {
+// GetRawContext returns the JSON of the MongoDB resource as stored, with the
+// fields MongoSpec doesn't model.
+func (c *mongoDBClient) GetRawContext(ctx context.Context, name string) ([]byte, error) {
+	return c.request(ctx, c.restClient.Get()).
+		Name(name).
+		Do().
+		Raw()
+}
+
+func (c *mongoDBClient) CreateContext(ctx context.Context, mongodb *v1.MongoDB) (*v1.MongoDB, error) {
 	result := v1.MongoDB{}
-	err := c.restClient.
-		Post().
-		Namespace(c.ns).
-		Resource("mongodb").
+	err := c.request(ctx, c.restClient.Post()).
 		Body(mongodb).
 		Do().
 		Into(&result)
 	return &result, err
 }
 
-func (c *mongoDBClient) Delete(name string) error {
-	return c.restClient.Delete().
-		Namespace(c.ns).
-		Resource("mongodb").
+// UpdateContext replaces the MongoDB resource. The resourceVersion carried in
+// the object metadata is checked by the API server, so a stale object results
+// in a Conflict error instead of overwriting somebody else's changes.
+func (c *mongoDBClient) UpdateContext(ctx context.Context, mongodb *v1.MongoDB) (*v1.MongoDB, error) {
+	result := v1.MongoDB{}
+	err := c.request(ctx, c.restClient.Put()).
+		Name(mongodb.Name).
+		Body(mongodb).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+// PatchContext applies a JSON merge or JSON patch to the MongoDB resource,
+// depending on the given patch type. Strategic merge patches are rejected by
+// the API server for custom resources.
+func (c *mongoDBClient) PatchContext(ctx context.Context, name string, pt types.PatchType, data []byte) (*v1.MongoDB, error) {
+	result := v1.MongoDB{}
+	err := c.request(ctx, c.restClient.Patch(pt)).
+		Name(name).
+		Body(data).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+// GetStatusContext reads the MongoDB resource through its status
+// subresource.
+func (c *mongoDBClient) GetStatusContext(ctx context.Context, name string) (*v1.MongoDB, error) {
+	result := v1.MongoDB{}
+	err := c.request(ctx, c.restClient.Get()).
+		Name(name).
+		SubResource("status").
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+// UpdateStatusContext writes the status of the MongoDB resource. Changes to
+// the spec are ignored by the API server on this subresource.
+func (c *mongoDBClient) UpdateStatusContext(ctx context.Context, mongodb *v1.MongoDB) (*v1.MongoDB, error) {
+	result := v1.MongoDB{}
+	err := c.request(ctx, c.restClient.Put()).
+		Name(mongodb.Name).
+		SubResource("status").
+		Body(mongodb).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+func (c *mongoDBClient) DeleteContext(ctx context.Context, name string) error {
+	return c.request(ctx, c.restClient.Delete()).
 		Name(name).
 		Do().
 		Error()
 }
 
-func (c *mongoDBClient) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+// WatchContext streams changes until the watch is stopped or ctx is done. The
+// call timeout doesn't apply to watches.
+func (c *mongoDBClient) WatchContext(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
 	opts.Watch = true
 	return c.restClient.
 		Get().
+		Context(ctx).
 		Namespace(c.ns).
 		Resource("mongodb").
 		VersionedParams(&opts, scheme.ParameterCodec).
 		Watch()
 }
+
+// WaitForPhaseContext blocks until the named MongoDB resource reports the
+// given phase in its status, using a watch instead of polling. The last
+// observed object is returned alongside ErrWaitTimeout, ErrPhaseFailed,
+// ErrResourceDeleted or the error of ctx, so callers can report how far the
+// operator got.
+func (c *mongoDBClient) WaitForPhaseContext(ctx context.Context, name string, phase v1.Phase, timeout time.Duration) (*v1.MongoDB, error) {
+	deadline := time.After(timeout)
+	for {
+		last, err := c.GetContext(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if done, err := phaseReached(last, phase); done {
+			return last, err
+		}
+		watcher, err := c.WatchContext(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: last.ResourceVersion,
+		})
+		if err != nil {
+			return last, err
+		}
+		last, err = c.waitOnWatcher(ctx, watcher, last, phase, deadline)
+		watcher.Stop()
+		if err != errWatchClosed {
+			return last, err
+		}
+	}
+}
+
+// errWatchClosed signals that the API server closed the watch before the
+// phase was reached, so the resource is read again and a new watch started.
+var errWatchClosed = errors.New("watch closed")
+
+func (c *mongoDBClient) waitOnWatcher(ctx context.Context, watcher watch.Interface, last *v1.MongoDB, phase v1.Phase, deadline <-chan time.Time) (*v1.MongoDB, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-deadline:
+			return last, ErrWaitTimeout
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return last, errWatchClosed
+			}
+			switch event.Type {
+			case watch.Error:
+				return last, errWatchClosed
+			case watch.Deleted:
+				return last, ErrResourceDeleted
+			}
+			mongodb, ok := event.Object.(*v1.MongoDB)
+			if !ok {
+				continue
+			}
+			last = mongodb
+			if done, err := phaseReached(last, phase); done {
+				return last, err
+			}
+		}
+	}
+}
+
+func phaseReached(mongodb *v1.MongoDB, phase v1.Phase) (bool, error) {
+	switch mongodb.Status.Phase {
+	case phase:
+		return true, nil
+	case v1.PhaseFailed:
+		return true, ErrPhaseFailed
+	}
+	return false, nil
+}
@@ -0,0 +1,38 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context tagging the calls made with it with
+// the ID of the web request they are made for: in the X-Request-ID header and
+// in the user agent, which API server audit events record.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID carried by ctx, if any.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDRoundTripper tags every call whose context carries a request ID.
+// It runs after client-go has set the user agent, which it extends.
+type requestIDRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (rt *requestIDRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := RequestIDFrom(req.Context())
+	if id == "" {
+		return rt.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Request-ID", id)
+	req.Header.Set("User-Agent", req.Header.Get("User-Agent")+" request-id/"+id)
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fuzzer fills every field, leaving no pointer, map or slice nil, so that a
+// field the generated functions forget to copy, or copy by reference, shows
+// up in the comparisons below. The same seed always fills the same values.
+func fuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.New().NilChance(0).NumElements(1, 3).MaxDepth(8).RandSource(rand.NewSource(seed))
+}
+
+func TestDeepCopy(t *testing.T) {
+	objects := []struct {
+		name string
+		new  func() runtime.Object
+	}{
+		{"MongoDB", func() runtime.Object { return &MongoDB{} }},
+		{"MongoDBList", func() runtime.Object { return &MongoDBList{} }},
+	}
+	for _, object := range objects {
+		for seed := int64(0); seed < 20; seed++ {
+			original, reference := object.new(), object.new()
+			fuzzer(seed).Fuzz(original)
+			fuzzer(seed).Fuzz(reference)
+
+			copied := original.DeepCopyObject()
+			if !reflect.DeepEqual(original, copied) {
+				t.Fatalf("%s, seed %d: the copy differs from the original\noriginal: %#v\ncopy:     %#v", object.name, seed, original, copied)
+			}
+			mutate(reflect.ValueOf(copied))
+			if reflect.DeepEqual(original, copied) {
+				t.Fatalf("%s, seed %d: mutating the copy had no effect", object.name, seed)
+			}
+			if !reflect.DeepEqual(original, reference) {
+				t.Fatalf("%s, seed %d: mutating the copy changed the original, which shares memory with it", object.name, seed)
+			}
+		}
+	}
+}
+
+// mutate changes every value reachable from v, through pointers, slice
+// elements and map values alike.
+func mutate(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			mutate(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				mutate(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			mutate(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			mutate(value)
+			v.SetMapIndex(key, value)
+		}
+	case reflect.String:
+		v.SetString(v.String() + "-mutated")
+	case reflect.Bool:
+		v.SetBool(!v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(v.Uint() + 1)
+	}
+}
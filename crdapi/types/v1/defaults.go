@@ -0,0 +1,49 @@
+package v1
+
+const (
+	DefaultReplicaSetMembers    = 3
+	DefaultConfigServerCount    = 3
+	DefaultMongoDsPerShardCount = 3
+	DefaultMongosCount          = 2
+	DefaultShardCount           = 2
+)
+
+// SpecDefaults holds the installation specific values used when a MongoSpec
+// leaves the version or the Ops Manager references empty.
+// +k8s:deepcopy-gen=false
+type SpecDefaults struct {
+	Version     string
+	Project     string
+	Credentials string
+}
+
+// SetMongoSpecDefaults fills in every field of the spec that was left at its
+// zero value. Counts are defaulted based on the deployment type, so they have
+// to be set before the spec is validated.
+func SetMongoSpecDefaults(spec *MongoSpec, defaults SpecDefaults) {
+	if spec.Version == "" {
+		spec.Version = defaults.Version
+	}
+	if spec.Project == "" {
+		spec.Project = defaults.Project
+	}
+	if spec.Credentials == "" {
+		spec.Credentials = defaults.Credentials
+	}
+
+	switch spec.Type {
+	case ReplicaSet:
+		setDefaultCount(&spec.Members, DefaultReplicaSetMembers)
+	case ShardedCluster:
+		setDefaultCount(&spec.ConfigServerCount, DefaultConfigServerCount)
+		setDefaultCount(&spec.MongoDsPerShardCount, DefaultMongoDsPerShardCount)
+		setDefaultCount(&spec.MongosCount, DefaultMongosCount)
+		setDefaultCount(&spec.ShardCount, DefaultShardCount)
+	}
+}
+
+func setDefaultCount(count *int, value int) {
+	if *count == 0 {
+		*count = value
+	}
+}
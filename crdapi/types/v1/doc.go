@@ -0,0 +1,8 @@
+// +k8s:deepcopy-gen=package
+// +groupName=mongodb.com
+
+// Package v1 contains the mongodb.com/v1 API types handled by the MongoDB
+// Enterprise Kubernetes operator.
+package v1
+
+//go:generate sh ../../../hack/update-codegen.sh
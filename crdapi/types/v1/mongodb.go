@@ -2,6 +2,12 @@ package v1
 
 import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+const (
+	Standalone     = "Standalone"
+	ReplicaSet     = "ReplicaSet"
+	ShardedCluster = "ShardedCluster"
+)
+
 type MongoSpec struct {
 	Credentials string `json:"credentials"`
 	Project     string `json:"project"`
@@ -42,12 +48,45 @@ type TLSSpec struct {
 	CA      string `json:"ca,omitempty"`
 }
 
+type Phase string
+
+const (
+	PhasePending     Phase = "Pending"
+	PhaseReconciling Phase = "Reconciling"
+	PhaseRunning     Phase = "Running"
+	PhaseFailed      Phase = "Failed"
+)
+
+type MongoDBStatus struct {
+	Phase              Phase              `json:"phase,omitempty"`
+	Message            string             `json:"message,omitempty"`
+	Version            string             `json:"version,omitempty"`
+	Members            int                `json:"members,omitempty"`
+	Link               string             `json:"link,omitempty"`
+	LastTransition     string             `json:"lastTransition,omitempty"`
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []MongoDBCondition `json:"conditions,omitempty"`
+}
+
+type MongoDBCondition struct {
+	Type               string                 `json:"type"`
+	Status             metav1.ConditionStatus `json:"status"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
 type MongoDB struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata"`
-	Spec              MongoSpec `json:"spec"`
+	Spec              MongoSpec     `json:"spec"`
+	Status            MongoDBStatus `json:"status,omitempty"`
 }
 
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
 type MongoDBList struct {
 	metav1.TypeMeta `json:",inline"`
 	metav1.ListMeta `json:"metadata,omitempty"`
@@ -0,0 +1,198 @@
+// Package validation checks MongoDB resources before they are submitted to
+// the API server, so that mistakes are reported to the caller instead of
+// being picked up later by the operator.
+package validation
+
+import (
+	"context"
+	"regexp"
+
+	clientv1 "github.com/10gen/dredd/clientset/v1"
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var versionRegexp = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+var supportedTypes = []string{typesv1.Standalone, typesv1.ReplicaSet, typesv1.ShardedCluster}
+
+var supportedLogLevels = []string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+var supportedAuthenticationModes = []string{"x509"}
+
+var supportedSSLModes = []string{"disabled", "allowSSL", "preferSSL", "requireSSL"}
+
+// ValidateMongoDB validates the spec of a MongoDB resource, including the
+// existence of the ConfigMaps and Secrets it references. The error is set
+// when a reference could not be checked, for example because the caller may
+// not read it or the API server is unavailable; it is not a validation
+// failure and is returned as is.
+func ValidateMongoDB(ctx context.Context, mongodb *typesv1.MongoDB, core clientv1.CoreInterface) (field.ErrorList, error) {
+	specPath := field.NewPath("spec")
+	allErrs := ValidateMongoSpec(&mongodb.Spec, specPath)
+	refErrs, err := ValidateMongoSpecReferences(ctx, &mongodb.Spec, core, specPath)
+	if err != nil {
+		return nil, err
+	}
+	return append(allErrs, refErrs...), nil
+}
+
+// ValidateMongoSpec performs the checks that don't need the API server:
+// required fields for the deployment type, version format and the
+// consistency of the security settings.
+func ValidateMongoSpec(spec *typesv1.MongoSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.Project == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("project"), "name of the Ops Manager project ConfigMap"))
+	}
+	if spec.Credentials == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("credentials"), "name of the Ops Manager credentials Secret"))
+	}
+	if spec.Version == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("version"), ""))
+	} else if !versionRegexp.MatchString(spec.Version) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("version"), spec.Version, "must be a semantic version such as 4.0.9 or 4.0.9-ent"))
+	}
+	if spec.LogLevel != "" && !contains(supportedLogLevels, spec.LogLevel) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("logLevel"), spec.LogLevel, supportedLogLevels))
+	}
+
+	allErrs = append(allErrs, validateTopology(spec, fldPath)...)
+	allErrs = append(allErrs, validateSecurity(spec, fldPath)...)
+	return allErrs
+}
+
+func validateTopology(spec *typesv1.MongoSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	shardedCounts := []struct {
+		name  string
+		count int
+	}{
+		{"shardCount", spec.ShardCount},
+		{"mongodsPerShardCount", spec.MongoDsPerShardCount},
+		{"mongosCount", spec.MongosCount},
+		{"configServerCount", spec.ConfigServerCount},
+	}
+
+	switch spec.Type {
+	case "":
+		allErrs = append(allErrs, field.Required(fldPath.Child("type"), ""))
+	case typesv1.Standalone:
+		allErrs = append(allErrs, forbidCount(fldPath.Child("members"), spec.Members, spec.Type)...)
+		for _, c := range shardedCounts {
+			allErrs = append(allErrs, forbidCount(fldPath.Child(c.name), c.count, spec.Type)...)
+		}
+	case typesv1.ReplicaSet:
+		allErrs = append(allErrs, requireCount(fldPath.Child("members"), spec.Members)...)
+		for _, c := range shardedCounts {
+			allErrs = append(allErrs, forbidCount(fldPath.Child(c.name), c.count, spec.Type)...)
+		}
+	case typesv1.ShardedCluster:
+		allErrs = append(allErrs, forbidCount(fldPath.Child("members"), spec.Members, spec.Type)...)
+		for _, c := range shardedCounts {
+			allErrs = append(allErrs, requireCount(fldPath.Child(c.name), c.count)...)
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), spec.Type, supportedTypes))
+	}
+	return allErrs
+}
+
+func validateSecurity(spec *typesv1.MongoSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	securityPath := fldPath.Child("security")
+	tlsPath := securityPath.Child("tls")
+	tls := spec.Security.TLS
+
+	if tls.CA != "" && !tls.Enabled {
+		allErrs = append(allErrs, field.Forbidden(tlsPath.Child("ca"), "may only be set when TLS is enabled"))
+	}
+
+	authMode := spec.Security.ClusterAuthenticationMode
+	authPath := securityPath.Child("clusterAuthenticationMode")
+	if authMode != "" {
+		if !contains(supportedAuthenticationModes, authMode) {
+			allErrs = append(allErrs, field.NotSupported(authPath, authMode, supportedAuthenticationModes))
+		} else if !tls.Enabled {
+			allErrs = append(allErrs, field.Invalid(authPath, authMode, "x509 cluster authentication requires TLS to be enabled"))
+		}
+	}
+
+	sslMode := spec.AdditionalMongoDConfig.Net.SSL.Mode
+	sslPath := fldPath.Child("additionalMongodConfig", "net", "ssl", "mode")
+	if sslMode != "" {
+		if !contains(supportedSSLModes, sslMode) {
+			allErrs = append(allErrs, field.NotSupported(sslPath, sslMode, supportedSSLModes))
+		} else if sslMode != "disabled" && !tls.Enabled {
+			allErrs = append(allErrs, field.Invalid(sslPath, sslMode, "requires TLS to be enabled"))
+		}
+	}
+	return allErrs
+}
+
+// ValidateMongoSpecReferences checks that the project ConfigMap, the
+// credentials Secret and, when TLS is enabled, the CA ConfigMap exist. A
+// missing one is a field error; any other failure to read one is returned as
+// the error.
+func ValidateMongoSpecReferences(ctx context.Context, spec *typesv1.MongoSpec, core clientv1.CoreInterface, fldPath *field.Path) (field.ErrorList, error) {
+	allErrs := field.ErrorList{}
+	var err error
+	if spec.Project != "" {
+		_, err = core.GetConfigMapContext(ctx, spec.Project)
+		if allErrs, err = appendReferenceError(allErrs, fldPath.Child("project"), spec.Project, err); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Credentials != "" {
+		_, err = core.GetSecretContext(ctx, spec.Credentials)
+		if allErrs, err = appendReferenceError(allErrs, fldPath.Child("credentials"), spec.Credentials, err); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Security.TLS.Enabled && spec.Security.TLS.CA != "" {
+		_, err = core.GetConfigMapContext(ctx, spec.Security.TLS.CA)
+		if allErrs, err = appendReferenceError(allErrs, fldPath.Child("security", "tls", "ca"), spec.Security.TLS.CA, err); err != nil {
+			return nil, err
+		}
+	}
+	return allErrs, nil
+}
+
+// appendReferenceError adds a NotFound field error when the referenced object
+// is missing, and passes any other error on.
+func appendReferenceError(allErrs field.ErrorList, fldPath *field.Path, name string, err error) (field.ErrorList, error) {
+	switch {
+	case err == nil:
+		return allErrs, nil
+	case apierrors.IsNotFound(err):
+		return append(allErrs, field.NotFound(fldPath, name)), nil
+	default:
+		return nil, err
+	}
+}
+
+func requireCount(fldPath *field.Path, count int) field.ErrorList {
+	if count < 1 {
+		return field.ErrorList{field.Invalid(fldPath, count, "must be greater than or equal to 1")}
+	}
+	return nil
+}
+
+func forbidCount(fldPath *field.Path, count int, deploymentType string) field.ErrorList {
+	if count != 0 {
+		return field.ErrorList{field.Forbidden(fldPath, "may not be set for a "+deploymentType)}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
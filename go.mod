@@ -1,7 +1,11 @@
 module github.com/10gen/dredd
 
 require (
+	github.com/evanphx/json-patch v0.0.0-20190203023257-5858425f7550
+	github.com/fsnotify/fsnotify v1.4.7
+	github.com/google/gofuzz v0.0.0-20170612174753-24818f796faf
 	github.com/gorilla/mux v1.7.3
+	github.com/prometheus/client_golang v0.9.4
 	go.uber.org/atomic v1.4.0 // indirect
 	go.uber.org/multierr v1.1.0 // indirect
 	go.uber.org/zap v1.10.0
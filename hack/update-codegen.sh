@@ -0,0 +1,13 @@
+#!/bin/sh
+# Regenerates the deepcopy functions of the CRD types with a pinned
+# deepcopy-gen. Run through go generate ./... or directly, from anywhere.
+set -e
+cd "$(dirname "$0")/.."
+
+go run k8s.io/code-generator/cmd/deepcopy-gen@v0.29.0 \
+	--input-dirs github.com/10gen/dredd/crdapi/types/v1 \
+	--output-package github.com/10gen/dredd/crdapi/types/v1 \
+	--output-file-base zz_generated.deepcopy \
+	--output-base . \
+	--trim-path-prefix github.com/10gen/dredd \
+	--go-header-file /dev/null
@@ -2,28 +2,74 @@ package logging
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 )
 
-func InitLogger(preset string) (*zap.SugaredLogger, error) {
-	var zapLogger *zap.Logger
-	var err error
+// Options selects the logger. Preset is DEV or PROD; Level and Encoding
+// override the preset's own, debug and console for DEV, info and json for
+// PROD.
+type Options struct {
+	Preset   string
+	Level    string
+	Encoding string
+}
+
+// Level is shared by every logger built by InitLogger, so changing it, for
+// example through its HTTP handler, applies to the current logger and
+// survives a configuration reload that keeps the same level.
+var Level = zap.NewAtomicLevel()
+
+// audit holds the *zap.Logger for audit records built by InitLogger.
+var audit atomic.Value
+
+// Audit returns the logger for audit records. It writes to the same output
+// as the other loggers but always at info level, whatever Level is set to,
+// and without sampling, so no record is ever dropped. Before InitLogger it
+// falls back to the global logger.
+func Audit() *zap.Logger {
+	if logger, ok := audit.Load().(*zap.Logger); ok {
+		return logger
+	}
+	return zap.L().Named("audit")
+}
 
-	switch preset {
+func InitLogger(opts Options) (*zap.SugaredLogger, error) {
+	var config zap.Config
+	switch opts.Preset {
 	case "PROD":
-		zapLogger, err = zap.NewProduction()
+		config = zap.NewProductionConfig()
 	case "DEV":
-		zapLogger, err = zap.NewDevelopment()
+		config = zap.NewDevelopmentConfig()
+	default:
+		return nil, fmt.Errorf("unknown logger preset %q, use DEV or PROD", opts.Preset)
 	}
 
-	if err != nil {
-		fmt.Println("Failed to create logger, will use the default one")
-		fmt.Println(err)
+	level := config.Level.Level()
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Encoding != "" {
+		config.Encoding = opts.Encoding
 	}
+	auditConfig := config
+	auditConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+	auditConfig.Sampling = nil
+	config.Level = Level
 
+	zapLogger, err := config.Build()
+	if err != nil {
+		return nil, err
+	}
+	auditLogger, err := auditConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+	Level.SetLevel(level)
 	zap.ReplaceGlobals(zapLogger)
-	var logger *zap.SugaredLogger
-	logger = zap.S()
-	return logger, nil
+	audit.Store(auditLogger.Named("audit"))
+	return zap.S(), nil
 }
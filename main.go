@@ -1,6 +1,14 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
 	clientv1 "github.com/10gen/dredd/clientset/v1"
 	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
 
@@ -10,6 +18,7 @@ import (
 	"github.com/10gen/dredd/webapi/v1"
 
 	"go.uber.org/zap"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
@@ -30,38 +39,149 @@ func InitialiseKubernetesConfig(kubeconfig string) (*rest.Config, error) {
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// run starts the API server and returns the process exit code: 0 after a
+// clean shutdown, 1 when the server failed to start or to stop.
+func run() int {
+	configFile := appconfig.DefaultConfigFile
+	if file, ok := os.LookupEnv(appconfig.EnvPrefix + "CONFIG"); ok {
+		configFile = file
+	}
+	flag.StringVar(&configFile, "config", configFile, "path of the configuration file, also set by "+appconfig.EnvPrefix+"CONFIG")
+	flag.Parse()
+
 	var err error
 	var appConfig *appconfig.AppConf
-	appConfig, err = appconfig.GetConf()
+	appConfig, err = appconfig.GetConf(configFile)
 	if err != nil {
-		panic(err.Error)
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %s\n", err.Error())
+		return 1
 	}
 
-	logger, err := logging.InitLogger(appConfig.Logger)
+	logger, err := logging.InitLogger(loggingOptions(appConfig))
 	if err != nil {
-		logger.Panic(err.Error())
+		fmt.Fprintf(os.Stderr, "Failed to initialise logger: %s\n", err.Error())
+		return 1
 	}
-	logger.Infof("ZAP logger initialised in %s mode", appConfig.Logger)
+	defer logger.Sync()
+	logger.Infof("ZAP logger initialised in %s mode at %s level", appConfig.Logger, logging.Level)
 
 	zap.S().Info("Loading Kubernetes cluster configuration")
-	config, err := InitialiseKubernetesConfig(appConfig.Kubernetes["kubeconfig"])
+	config, err := InitialiseKubernetesConfig(appConfig.Kubernetes.Kubeconfig)
 	if err != nil {
-		zap.S().Panic(err.Error())
+		logger.Errorf("Failed to load Kubernetes configuration: %s", err.Error())
+		return 1
 	}
 
 	zap.S().Info("Initialising KubeClient")
 	clientSet, err := clientv1.NewForConfig(config)
 	if err != nil {
-		logger.Panic(err.Error())
+		logger.Errorf("Failed to initialise KubeClient: %s", err.Error())
+		return 1
 	}
+	clientSet = clientSet.WithTimeout(appConfig.Kubernetes.RequestTimeout)
 
 	logger.Infof("Addig schemes")
 	typesv1.AddToScheme(scheme.Scheme)
 
 	// Web Server
-	zap.S().Info("Initialising API server on port 8080")
-	err = webapi.ServeAPI(":8080", clientSet, appConfig.Kubernetes["namespace"])
+	minTLSVersion, err := webapi.ParseTLSVersion(appConfig.Server.TLS.MinVersion)
+	if err != nil {
+		logger.Errorf("Invalid server configuration: %s", err.Error())
+		return 1
+	}
+	updates := make(chan webapi.Settings, 1)
+	opts := webapi.Options{
+		Settings:       settingsFor(appConfig),
+		Authentication: appConfig.Auth.Enabled,
+		Updates:        updates,
+		Server: webapi.ServerOptions{
+			Address:             appConfig.Server.Address,
+			ReadTimeout:         appConfig.Server.ReadTimeout,
+			WriteTimeout:        appConfig.Server.WriteTimeout,
+			IdleTimeout:         appConfig.Server.IdleTimeout,
+			MaxHeaderBytes:      appConfig.Server.MaxHeaderBytes,
+			ShutdownGracePeriod: appConfig.Server.ShutdownGracePeriod,
+			TLS: webapi.TLSOptions{
+				CertFile:                appConfig.Server.TLS.CertFile,
+				KeyFile:                 appConfig.Server.TLS.KeyFile,
+				ClientCAFile:            appConfig.Server.TLS.ClientCAFile,
+				VerifyClientCertIfGiven: appConfig.Server.TLS.OptionalClientCert,
+				MinVersion:              minTLSVersion,
+				ReloadInterval:          appConfig.Server.TLS.ReloadInterval,
+			},
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	go func() {
+		err := appconfig.Watch(ctx, configFile, hangup, func(reloaded *appconfig.AppConf) {
+			if restart := appconfig.RestartRequired(appConfig, reloaded); len(restart) > 0 {
+				zap.S().Warnf("Changed settings only take effect after a restart: %s", strings.Join(restart, ", "))
+			}
+			if reloaded.Logger != appConfig.Logger || reloaded.Logging != appConfig.Logging {
+				if _, err := logging.InitLogger(loggingOptions(reloaded)); err != nil {
+					zap.S().Errorf("Failed to reconfigure the logger: %s", err.Error())
+				} else {
+					zap.S().Infof("ZAP logger switched to %s mode at %s level", reloaded.Logger, logging.Level)
+				}
+			}
+			select {
+			case updates <- settingsFor(reloaded):
+			case <-ctx.Done():
+			}
+			appConfig = reloaded
+		})
+		if err != nil {
+			zap.S().Errorf("Configuration hot reload disabled: %s", err.Error())
+		}
+	}()
+
+	if opts.Server.TLS.Enabled() {
+		zap.S().Infof("Initialising API server on %s with TLS", opts.Server.Address)
+	} else {
+		zap.S().Infof("Initialising API server on %s", opts.Server.Address)
+	}
+	err = webapi.ServeAPI(ctx, clientSet, opts)
 	if err != nil {
-		logger.Panic(err.Error())
+		logger.Errorf("API server failed: %s", err.Error())
+		return 1
+	}
+	logger.Info("API server stopped")
+	return 0
+}
+
+func loggingOptions(appConfig *appconfig.AppConf) logging.Options {
+	return logging.Options{
+		Preset:   appConfig.Logger,
+		Level:    appConfig.Logging.Level,
+		Encoding: appConfig.Logging.Encoding,
+	}
+}
+
+// settingsFor extracts the web API settings that can be reloaded.
+func settingsFor(appConfig *appconfig.AppConf) webapi.Settings {
+	settings := webapi.Settings{
+		Namespace:         appConfig.Kubernetes.Namespace,
+		AllowedNamespaces: appConfig.AllowedNamespaces,
+		Defaults: typesv1.SpecDefaults{
+			Version:     appConfig.Defaults.Version,
+			Project:     appConfig.Defaults.Project,
+			Credentials: appConfig.Defaults.Credentials,
+		},
+	}
+	for _, static := range appConfig.Auth.StaticTokens {
+		settings.StaticTokens = append(settings.StaticTokens, webapi.StaticToken{
+			Token: static.Token,
+			User:  authenticationv1.UserInfo{Username: static.User, Groups: static.Groups},
+		})
 	}
+	return settings
 }
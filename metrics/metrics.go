@@ -0,0 +1,58 @@
+// Package metrics holds the Prometheus collectors shared by the web API and
+// the Kubernetes clientset.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "gokube"
+
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests served, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests, by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	KubeClientRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kube_client",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of Kubernetes API calls, by verb and resource.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"verb", "resource"})
+
+	KubeClientErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kube_client",
+		Name:      "errors_total",
+		Help:      "Number of failed Kubernetes API calls, by verb, resource and status code.",
+	}, []string{"verb", "resource", "code"})
+
+	WatchStreamsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "watch_streams_in_flight",
+		Help:      "Number of MongoDB event streams currently open.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		KubeClientRequestDuration,
+		KubeClientErrorsTotal,
+		WatchStreamsInFlight,
+	)
+}
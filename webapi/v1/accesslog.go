@@ -0,0 +1,49 @@
+package webapi
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// quietRoutes are polled by Kubernetes and Prometheus, so their access lines
+// are only logged at debug level.
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// accessLogMiddleware logs one structured line per request once the
+// response is complete.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		route := routeTemplate(r)
+		level := zapcore.InfoLevel
+		if quietRoutes[route] {
+			level = zapcore.DebugLevel
+		}
+		logger := zap.L().Named("access")
+		if entry := logger.Check(level, "HTTP request"); entry != nil {
+			entry.Write(
+				zap.String("method", r.Method),
+				zap.String("route", route),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", recorder.status),
+				zap.Duration("latency", time.Since(start)),
+				zap.Int("bytes", recorder.bytes),
+				zap.String("remoteAddr", r.RemoteAddr),
+				zap.String("requestID", requestIDFor(r)),
+			)
+		}
+	})
+}
@@ -0,0 +1,330 @@
+package webapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	clientv1 "github.com/10gen/dredd/clientset/v1"
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+
+	"github.com/gorilla/mux"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	clientContextKey
+)
+
+// The KubeClients impersonating callers are kept for the callers' next
+// requests, up to impersonatingClientCacheSize of them.
+const (
+	impersonatingClientCacheSize = 256
+	impersonatingClientTTL       = 10 * time.Minute
+)
+
+// StaticToken is a bearer token accepted without asking the API server,
+// meant for local use.
+type StaticToken struct {
+	Token string
+	User  authenticationv1.UserInfo
+}
+
+// clientFor returns the KubeClient impersonating the caller of the request,
+// or the server's own client when authentication is disabled.
+func (eh *WebAPIHandler) clientFor(r *http.Request) *clientv1.KubeClient {
+	if client, ok := r.Context().Value(clientContextKey).(*clientv1.KubeClient); ok {
+		return client
+	}
+	return eh.kubeClient
+}
+
+// userFor returns the authenticated caller of the request, if any.
+func userFor(r *http.Request) (*authenticationv1.UserInfo, bool) {
+	user, ok := r.Context().Value(userContextKey).(*authenticationv1.UserInfo)
+	return user, ok
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[len("Bearer "):])
+}
+
+// authenticate resolves a bearer token to a user, first against the static
+// tokens from the configuration and then through a TokenReview, whose
+// status is cached.
+func (eh *WebAPIHandler) authenticate(r *http.Request, token string) (*authenticationv1.UserInfo, error) {
+	for _, static := range eh.settings().StaticTokens {
+		if subtle.ConstantTimeCompare([]byte(static.Token), []byte(token)) == 1 {
+			user := static.User
+			return &user, nil
+		}
+	}
+	status, err := eh.reviews.reviewToken(r.Context(), eh.clientFor(r).Auth(), token)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Authenticated {
+		if status.Error != "" {
+			return nil, fmt.Errorf("invalid bearer token: %s", status.Error)
+		}
+		return nil, fmt.Errorf("invalid bearer token")
+	}
+	return &status.User, nil
+}
+
+// authMiddleware authenticates the caller, checks with a SubjectAccessReview
+// that the caller may perform every Kubernetes action the route implies and
+// hands the handlers a KubeClient impersonating the caller.
+func (eh *WebAPIHandler) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gokube"`)
+			RespondWithError(w, http.StatusUnauthorized, "A bearer token is required")
+			return
+		}
+		user, err := eh.authenticate(r, token)
+		if err != nil {
+			logFor(r).Infof("Authentication failed: %s", err.Error())
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gokube", error="invalid_token"`)
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		for _, attributes := range eh.resourceAttributesFor(r) {
+			status, err := eh.reviews.reviewAccess(r.Context(), eh.clientFor(r).Auth(), *user, attributes)
+			if err != nil {
+				RespondWithAPIError(w, r, err)
+				return
+			}
+			if !status.Allowed {
+				msg := accessDeniedMessage(user.Username, attributes, status.Reason)
+				logFor(r).Infof(msg)
+				RespondWithError(w, http.StatusForbidden, msg)
+				return
+			}
+		}
+		for _, attributes := range nonResourceAttributesFor(r) {
+			status, err := eh.reviews.reviewNonResourceAccess(r.Context(), eh.clientFor(r).Auth(), *user, attributes)
+			if err != nil {
+				RespondWithAPIError(w, r, err)
+				return
+			}
+			if !status.Allowed {
+				msg := pathDeniedMessage(user.Username, attributes, status.Reason)
+				logFor(r).Infof(msg)
+				RespondWithError(w, http.StatusForbidden, msg)
+				return
+			}
+		}
+		client, err := eh.impersonating(*user)
+		if err != nil {
+			RespondWithAPIError(w, r, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		ctx = context.WithValue(ctx, clientContextKey, client)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// impersonating returns the KubeClient acting on behalf of user, reusing the
+// one built for the user's recent requests along with its connections.
+func (eh *WebAPIHandler) impersonating(user authenticationv1.UserInfo) (*clientv1.KubeClient, error) {
+	key, _ := json.Marshal(user)
+	if client, ok := eh.clients.Get(string(key)); ok {
+		return client.(*clientv1.KubeClient), nil
+	}
+	client, err := eh.kubeClient.Impersonate(user)
+	if err != nil {
+		return nil, err
+	}
+	eh.clients.Add(string(key), client, impersonatingClientTTL)
+	return client, nil
+}
+
+func accessDeniedMessage(username string, attributes authorizationv1.ResourceAttributes, reason string) string {
+	resource := attributes.Resource
+	if attributes.Group != "" {
+		resource += "." + attributes.Group
+	}
+	if attributes.Subresource != "" {
+		resource += "/" + attributes.Subresource
+	}
+	msg := fmt.Sprintf("User %q cannot %s resource %q", username, attributes.Verb, resource)
+	if attributes.Namespace != "" {
+		msg += fmt.Sprintf(" in namespace %q", attributes.Namespace)
+	}
+	if reason != "" {
+		msg += ": " + reason
+	}
+	return msg
+}
+
+func pathDeniedMessage(username string, attributes authorizationv1.NonResourceAttributes, reason string) string {
+	msg := fmt.Sprintf("User %q cannot %s path %q", username, attributes.Verb, attributes.Path)
+	if reason != "" {
+		msg += ": " + reason
+	}
+	return msg
+}
+
+// resourceAttributesFor lists every Kubernetes action performed by the route
+// matched by the request, including the reads and follow-up changes a
+// handler makes after its main action, so that a caller lacking any of them
+// is rejected before anything is changed. Routes that don't touch Kubernetes
+// resources only require authentication.
+func (eh *WebAPIHandler) resourceAttributesFor(r *http.Request) []authorizationv1.ResourceAttributes {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+	template = strings.TrimPrefix(template, "/namespaces/{ns}")
+	vars := mux.Vars(r)
+	namespace := eh.namespaceFor(r)
+	if allNamespacesRequested(r) {
+		namespace = metav1.NamespaceAll
+	}
+	attributes := func(group string, resource string, verb string) authorizationv1.ResourceAttributes {
+		return authorizationv1.ResourceAttributes{
+			Namespace: namespace,
+			Verb:      verb,
+			Group:     group,
+			Resource:  resource,
+			Name:      vars["name"],
+		}
+	}
+	// anyName is for the objects whose name isn't in the request path.
+	anyName := func(group string, resource string, verb string) authorizationv1.ResourceAttributes {
+		all := attributes(group, resource, verb)
+		all.Name = ""
+		return all
+	}
+
+	switch template {
+	case "/mongodbs":
+		all := []authorizationv1.ResourceAttributes{attributes(typesv1.GroupName, "mongodb", collectionVerb(r))}
+		if r.Method == http.MethodPost && r.URL.Query().Get("wait") != "" {
+			all = append(all,
+				anyName(typesv1.GroupName, "mongodb", "get"),
+				anyName(typesv1.GroupName, "mongodb", "watch"))
+		}
+		return all
+	case "/mongodbs/{name}":
+		verb := objectVerb(r)
+		all := []authorizationv1.ResourceAttributes{attributes(typesv1.GroupName, "mongodb", verb)}
+		if verb == "patch" {
+			all = append(all, attributes(typesv1.GroupName, "mongodb", "get"))
+		}
+		if verb == "delete" && boolQuery(r, "cascade") {
+			all = append(all,
+				attributes(typesv1.GroupName, "mongodb", "get"),
+				anyName(typesv1.GroupName, "mongodb", "list"),
+				anyName("", "configmaps", "delete"),
+				anyName("", "secrets", "delete"))
+		}
+		return all
+	case "/mongodbs/{name}/status":
+		status := attributes(typesv1.GroupName, "mongodb", "get")
+		status.Subresource = "status"
+		return []authorizationv1.ResourceAttributes{status}
+	case "/mongodbs/{name}/events":
+		return []authorizationv1.ResourceAttributes{attributes(typesv1.GroupName, "mongodb", "watch")}
+	case "/core/{component}":
+		if resource, ok := coreResources[vars["component"]]; ok {
+			return withReveal(r, []authorizationv1.ResourceAttributes{attributes("", resource, collectionVerb(r))})
+		}
+	case "/core/{component}/{name}":
+		if resource, ok := coreResources[vars["component"]]; ok {
+			all := []authorizationv1.ResourceAttributes{attributes("", resource, objectVerb(r))}
+			if resource == "secrets" && r.Method == http.MethodPut {
+				// Rotating credentials, or keeping the type of a
+				// Secret, reads it first.
+				all = append(all, attributes("", "secrets", "get"))
+				if boolQuery(r, "reconcile") {
+					all = append(all,
+						anyName(typesv1.GroupName, "mongodb", "list"),
+						anyName(typesv1.GroupName, "mongodb", "patch"))
+				}
+			}
+			return withReveal(r, all)
+		}
+	case "/projects":
+		// A failed project creation deletes what it already created.
+		return []authorizationv1.ResourceAttributes{
+			attributes("", "configmaps", "create"),
+			attributes("", "secrets", "create"),
+			attributes(typesv1.GroupName, "mongodb", "create"),
+			attributes("", "configmaps", "delete"),
+			attributes("", "secrets", "delete"),
+		}
+	}
+	return nil
+}
+
+// nonResourceAttributesFor lists the permissions on URL paths required by the
+// route matched by the request. Changing the log level affects the whole
+// server, so it takes the "put" verb on /loglevel, which RBAC grants through
+// the nonResourceURLs of a ClusterRole.
+func nonResourceAttributesFor(r *http.Request) []authorizationv1.NonResourceAttributes {
+	if r.Method == http.MethodPut && routeTemplate(r) == "/loglevel" {
+		return []authorizationv1.NonResourceAttributes{{Path: "/loglevel", Verb: "put"}}
+	}
+	return nil
+}
+
+// withReveal adds the permission needed to see secret data: the "get" verb
+// on the secrets/reveal subresource, which RBAC roles have to grant
+// explicitly on top of access to the secrets themselves.
+func withReveal(r *http.Request, all []authorizationv1.ResourceAttributes) []authorizationv1.ResourceAttributes {
+	if r.Method != http.MethodGet || !boolQuery(r, "reveal") || all[0].Resource != "secrets" {
+		return all
+	}
+	reveal := all[0]
+	reveal.Verb = "get"
+	reveal.Subresource = "reveal"
+	return append(all, reveal)
+}
+
+var coreResources = map[string]string{
+	"configmap": "configmaps",
+	"secret":    "secrets",
+}
+
+func collectionVerb(r *http.Request) string {
+	if r.Method == http.MethodPost {
+		return "create"
+	}
+	if boolQuery(r, "watch") {
+		return "watch"
+	}
+	return "list"
+}
+
+func objectVerb(r *http.Request) string {
+	switch r.Method {
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return "get"
+}
@@ -0,0 +1,185 @@
+package webapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+
+	"github.com/gorilla/mux"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// attributesRouter registers the routes the way ServeAPI does, with a
+// middleware standing in for authMiddleware that records the attributes it
+// would review instead of calling the handlers.
+func attributesRouter(resources *[]authorizationv1.ResourceAttributes, paths *[]authorizationv1.NonResourceAttributes) *mux.Router {
+	handler := &WebAPIHandler{}
+	handler.current.Store(&Settings{Namespace: "default", AllowedNamespaces: []string{"other"}})
+	router := mux.NewRouter()
+	apirouter := router.PathPrefix("/").Subrouter()
+	apirouter.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*resources = handler.resourceAttributesFor(r)
+			*paths = nonResourceAttributesFor(r)
+		})
+	})
+	InitialiseMongoDBRoutes(apirouter, handler)
+	InitialiseCoreRoutes(apirouter, handler)
+	InitialiseProjectRoutes(apirouter, handler)
+	InitialiseNamespacedRoutes(apirouter, handler)
+	apirouter.Methods("GET", "PUT").Path("/loglevel").HandlerFunc(logLevelHandler)
+	return router
+}
+
+func attrs(namespace, verb, group, resource, name string) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{Namespace: namespace, Verb: verb, Group: group, Resource: resource, Name: name}
+}
+
+func subresource(attributes authorizationv1.ResourceAttributes, subresource string) authorizationv1.ResourceAttributes {
+	attributes.Subresource = subresource
+	return attributes
+}
+
+func TestAttributesFor(t *testing.T) {
+	const group = typesv1.GroupName
+	tests := []struct {
+		method    string
+		target    string
+		resources []authorizationv1.ResourceAttributes
+		paths     []authorizationv1.NonResourceAttributes
+	}{
+		{"GET", "/mongodbs", []authorizationv1.ResourceAttributes{
+			attrs("default", "list", group, "mongodb", ""),
+		}, nil},
+		{"GET", "/mongodbs?watch=true", []authorizationv1.ResourceAttributes{
+			attrs("default", "watch", group, "mongodb", ""),
+		}, nil},
+		{"GET", "/mongodbs?allNamespaces=true", []authorizationv1.ResourceAttributes{
+			attrs("", "list", group, "mongodb", ""),
+		}, nil},
+		{"POST", "/mongodbs", []authorizationv1.ResourceAttributes{
+			attrs("default", "create", group, "mongodb", ""),
+		}, nil},
+		{"POST", "/mongodbs?wait=Running", []authorizationv1.ResourceAttributes{
+			attrs("default", "create", group, "mongodb", ""),
+			attrs("default", "get", group, "mongodb", ""),
+			attrs("default", "watch", group, "mongodb", ""),
+		}, nil},
+		{"GET", "/mongodbs/db", []authorizationv1.ResourceAttributes{
+			attrs("default", "get", group, "mongodb", "db"),
+		}, nil},
+		{"PUT", "/mongodbs/db", []authorizationv1.ResourceAttributes{
+			attrs("default", "update", group, "mongodb", "db"),
+		}, nil},
+		{"PATCH", "/mongodbs/db", []authorizationv1.ResourceAttributes{
+			attrs("default", "patch", group, "mongodb", "db"),
+			attrs("default", "get", group, "mongodb", "db"),
+		}, nil},
+		{"DELETE", "/mongodbs/db", []authorizationv1.ResourceAttributes{
+			attrs("default", "delete", group, "mongodb", "db"),
+		}, nil},
+		{"DELETE", "/mongodbs/db?cascade=true", []authorizationv1.ResourceAttributes{
+			attrs("default", "delete", group, "mongodb", "db"),
+			attrs("default", "get", group, "mongodb", "db"),
+			attrs("default", "list", group, "mongodb", ""),
+			attrs("default", "delete", "", "configmaps", ""),
+			attrs("default", "delete", "", "secrets", ""),
+		}, nil},
+		{"GET", "/mongodbs/db/status", []authorizationv1.ResourceAttributes{
+			subresource(attrs("default", "get", group, "mongodb", "db"), "status"),
+		}, nil},
+		{"GET", "/mongodbs/db/events", []authorizationv1.ResourceAttributes{
+			attrs("default", "watch", group, "mongodb", "db"),
+		}, nil},
+		{"GET", "/core/configmap", []authorizationv1.ResourceAttributes{
+			attrs("default", "list", "", "configmaps", ""),
+		}, nil},
+		{"GET", "/core/secret?reveal=true", []authorizationv1.ResourceAttributes{
+			attrs("default", "list", "", "secrets", ""),
+			subresource(attrs("default", "get", "", "secrets", ""), "reveal"),
+		}, nil},
+		{"GET", "/core/secret?watch=true", []authorizationv1.ResourceAttributes{
+			attrs("default", "watch", "", "secrets", ""),
+		}, nil},
+		{"POST", "/core/secret", []authorizationv1.ResourceAttributes{
+			attrs("default", "create", "", "secrets", ""),
+		}, nil},
+		{"POST", "/core/secret?reveal=true", []authorizationv1.ResourceAttributes{
+			attrs("default", "create", "", "secrets", ""),
+		}, nil},
+		{"GET", "/core/secret/creds", []authorizationv1.ResourceAttributes{
+			attrs("default", "get", "", "secrets", "creds"),
+		}, nil},
+		{"GET", "/core/secret/creds?reveal=true", []authorizationv1.ResourceAttributes{
+			attrs("default", "get", "", "secrets", "creds"),
+			subresource(attrs("default", "get", "", "secrets", "creds"), "reveal"),
+		}, nil},
+		{"PUT", "/core/configmap/project", []authorizationv1.ResourceAttributes{
+			attrs("default", "update", "", "configmaps", "project"),
+		}, nil},
+		{"PUT", "/core/secret/creds", []authorizationv1.ResourceAttributes{
+			attrs("default", "update", "", "secrets", "creds"),
+			attrs("default", "get", "", "secrets", "creds"),
+		}, nil},
+		{"PUT", "/core/secret/creds?reconcile=true", []authorizationv1.ResourceAttributes{
+			attrs("default", "update", "", "secrets", "creds"),
+			attrs("default", "get", "", "secrets", "creds"),
+			attrs("default", "list", group, "mongodb", ""),
+			attrs("default", "patch", group, "mongodb", ""),
+		}, nil},
+		{"DELETE", "/core/configmap/project", []authorizationv1.ResourceAttributes{
+			attrs("default", "delete", "", "configmaps", "project"),
+		}, nil},
+		{"GET", "/core/unknown", nil, nil},
+		{"POST", "/projects", []authorizationv1.ResourceAttributes{
+			attrs("default", "create", "", "configmaps", ""),
+			attrs("default", "create", "", "secrets", ""),
+			attrs("default", "create", group, "mongodb", ""),
+			attrs("default", "delete", "", "configmaps", ""),
+			attrs("default", "delete", "", "secrets", ""),
+		}, nil},
+		{"GET", "/namespaces/other/mongodbs/db", []authorizationv1.ResourceAttributes{
+			attrs("other", "get", group, "mongodb", "db"),
+		}, nil},
+		{"DELETE", "/namespaces/other/mongodbs/db?cascade=true", []authorizationv1.ResourceAttributes{
+			attrs("other", "delete", group, "mongodb", "db"),
+			attrs("other", "get", group, "mongodb", "db"),
+			attrs("other", "list", group, "mongodb", ""),
+			attrs("other", "delete", "", "configmaps", ""),
+			attrs("other", "delete", "", "secrets", ""),
+		}, nil},
+		{"PUT", "/namespaces/other/core/secret/creds?reconcile=true", []authorizationv1.ResourceAttributes{
+			attrs("other", "update", "", "secrets", "creds"),
+			attrs("other", "get", "", "secrets", "creds"),
+			attrs("other", "list", group, "mongodb", ""),
+			attrs("other", "patch", group, "mongodb", ""),
+		}, nil},
+		{"POST", "/namespaces/other/projects", []authorizationv1.ResourceAttributes{
+			attrs("other", "create", "", "configmaps", ""),
+			attrs("other", "create", "", "secrets", ""),
+			attrs("other", "create", group, "mongodb", ""),
+			attrs("other", "delete", "", "configmaps", ""),
+			attrs("other", "delete", "", "secrets", ""),
+		}, nil},
+		{"GET", "/namespaces", nil, nil},
+		{"GET", "/loglevel", nil, nil},
+		{"PUT", "/loglevel", nil, []authorizationv1.NonResourceAttributes{
+			{Path: "/loglevel", Verb: "put"},
+		}},
+	}
+	for _, test := range tests {
+		var resources []authorizationv1.ResourceAttributes
+		var paths []authorizationv1.NonResourceAttributes
+		router := attributesRouter(&resources, &paths)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(test.method, test.target, nil))
+		if !reflect.DeepEqual(resources, test.resources) {
+			t.Errorf("%s %s: resource attributes\ngot:  %+v\nwant: %+v", test.method, test.target, resources, test.resources)
+		}
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("%s %s: non-resource attributes\ngot:  %+v\nwant: %+v", test.method, test.target, paths, test.paths)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package webapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"time"
+
+	clientv1 "github.com/10gen/dredd/clientset/v1"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+// Like kube-rbac-proxy, reviews are remembered for a short while so a busy
+// client doesn't cost the API server two reviews per request. Negative
+// answers expire sooner, so a newly granted role takes effect quickly, and
+// errors aren't cached at all.
+const (
+	authCacheSize      = 1024
+	authenticatedTTL   = 2 * time.Minute
+	unauthenticatedTTL = 10 * time.Second
+	allowedTTL         = time.Minute
+	deniedTTL          = 10 * time.Second
+)
+
+// authCache holds the statuses of TokenReviews, keyed by the SHA-256 of the
+// token so the tokens themselves aren't kept in memory, and of
+// SubjectAccessReviews, keyed by the user and the attributes reviewed.
+type authCache struct {
+	tokens *cache.LRUExpireCache
+	access *cache.LRUExpireCache
+}
+
+func newAuthCache() *authCache {
+	return &authCache{
+		tokens: cache.NewLRUExpireCache(authCacheSize),
+		access: cache.NewLRUExpireCache(authCacheSize),
+	}
+}
+
+func (c *authCache) reviewToken(ctx context.Context, auth clientv1.AuthInterface, token string) (*authenticationv1.TokenReviewStatus, error) {
+	key := sha256.Sum256([]byte(token))
+	if status, ok := c.tokens.Get(key); ok {
+		return status.(*authenticationv1.TokenReviewStatus), nil
+	}
+	status, err := auth.ReviewTokenContext(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	ttl := unauthenticatedTTL
+	if status.Authenticated {
+		ttl = authenticatedTTL
+	}
+	c.tokens.Add(key, status, ttl)
+	return status, nil
+}
+
+func (c *authCache) reviewAccess(ctx context.Context, auth clientv1.AuthInterface, user authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error) {
+	key := accessKey(user, authorizationv1.SubjectAccessReviewSpec{ResourceAttributes: &attributes})
+	return c.cachedAccess(key, func() (*authorizationv1.SubjectAccessReviewStatus, error) {
+		return auth.ReviewAccessContext(ctx, user, attributes)
+	})
+}
+
+func (c *authCache) reviewNonResourceAccess(ctx context.Context, auth clientv1.AuthInterface, user authenticationv1.UserInfo, attributes authorizationv1.NonResourceAttributes) (*authorizationv1.SubjectAccessReviewStatus, error) {
+	key := accessKey(user, authorizationv1.SubjectAccessReviewSpec{NonResourceAttributes: &attributes})
+	return c.cachedAccess(key, func() (*authorizationv1.SubjectAccessReviewStatus, error) {
+		return auth.ReviewNonResourceAccessContext(ctx, user, attributes)
+	})
+}
+
+func (c *authCache) cachedAccess(key string, review func() (*authorizationv1.SubjectAccessReviewStatus, error)) (*authorizationv1.SubjectAccessReviewStatus, error) {
+	if status, ok := c.access.Get(key); ok {
+		return status.(*authorizationv1.SubjectAccessReviewStatus), nil
+	}
+	status, err := review()
+	if err != nil {
+		return nil, err
+	}
+	ttl := deniedTTL
+	if status.Allowed {
+		ttl = allowedTTL
+	}
+	c.access.Add(key, status, ttl)
+	return status, nil
+}
+
+// accessKey identifies a SubjectAccessReview by the whole user, groups and
+// extra included, and the attributes of the spec. Encoding the spec keeps
+// every field in the key, and maps are encoded with sorted keys.
+func accessKey(user authenticationv1.UserInfo, spec authorizationv1.SubjectAccessReviewSpec) string {
+	key, _ := json.Marshal(struct {
+		User authenticationv1.UserInfo
+		Spec authorizationv1.SubjectAccessReviewSpec
+	}{user, spec})
+	return string(key)
+}
@@ -2,11 +2,15 @@ package webapi
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 
+	clientv1 "github.com/10gen/dredd/clientset/v1"
+
 	"github.com/gorilla/mux"
-	"go.uber.org/zap"
 	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type ConfigMapBody struct {
@@ -21,14 +25,42 @@ type SecretBody struct {
 	ApiKey     string `json:"apiKey"`
 }
 
+// CredentialsBody replaces the Ops Manager API credentials of an existing
+// Secret.
+type CredentialsBody struct {
+	ApiUser         string `json:"apiUser"`
+	ApiKey          string `json:"apiKey"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type GenericConfigMapBody struct {
+	Name            string            `json:"name"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Data            map[string]string `json:"data"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+}
+
+// GenericSecretBody carries base64 encoded values in Data and plain text
+// values in StringData, like a Kubernetes Secret.
+type GenericSecretBody struct {
+	Name            string            `json:"name"`
+	Type            apiv1.SecretType  `json:"type,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Data            map[string][]byte `json:"data,omitempty"`
+	StringData      map[string]string `json:"stringData,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+}
+
 func (eh *WebAPIHandler) findCoreHandler(w http.ResponseWriter, r *http.Request) {
-	zap.S().Debugf("GET /core/{component}/{name}")
+	logFor(r).Debugf("GET /core/{component}/{name}")
 	vars := mux.Vars(r)
 	component, ok := vars["component"]
 	if !ok {
 		msg := "No component was specified in the request, this should either be 'configmap' or 'secret'"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Warnf("%s", msg)
+		logFor(r).Warnf("%s", msg)
 		return
 	}
 	var name string
@@ -36,157 +68,396 @@ func (eh *WebAPIHandler) findCoreHandler(w http.ResponseWriter, r *http.Request)
 	if !ok {
 		msg := "No name was specified in the request"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Warnf("%s", msg)
+		logFor(r).Warnf("%s", msg)
 		return
 	}
 	switch component {
 	case "configmap":
-		configMap, err := eh.kubeClient.Core(eh.namespace).GetConfigMap(name)
+		configMap, err := eh.core(r).GetConfigMapContext(r.Context(), name)
 		if err != nil {
-			RespondWithError(w, http.StatusNotFound, err.Error())
+			RespondWithAPIError(w, r, err)
 			return
 		}
 		RespondWithJSON(w, http.StatusOK, &configMap)
 	case "secret":
-		secret, err := eh.kubeClient.Core(eh.namespace).GetSecret(name)
+		reveal, ok := revealAllowed(w, r)
+		if !ok {
+			return
+		}
+		secret, err := eh.core(r).GetSecretContext(r.Context(), name)
 		if err != nil {
-			RespondWithError(w, http.StatusNotFound, err.Error())
+			RespondWithAPIError(w, r, err)
+			return
+		}
+		if reveal {
+			auditReveal(r, eh.namespaceFor(r), []string{secret.Name})
+			RespondWithJSON(w, http.StatusOK, &secret)
 			return
 		}
-		RespondWithJSON(w, http.StatusOK, &secret)
+		RespondWithJSON(w, http.StatusOK, redactSecret(secret))
 	default:
 		msg := "Resource doesn't exist"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Infof(msg)
+		logFor(r).Infof(msg)
 		return
 	}
 	_, ok = vars["name"]
 	if !ok {
 		msg := "No name was specified in the request"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Warnf(msg)
+		logFor(r).Warnf(msg)
 		return
 	}
 }
 
 func (eh *WebAPIHandler) allCoreHandler(w http.ResponseWriter, r *http.Request) {
-	zap.S().Debugf("GET /core/{component}")
+	logFor(r).Debugf("GET /core/{component}")
 	vars := mux.Vars(r)
 	component, ok := vars["component"]
 	if !ok {
 		msg := "No component was specified in the request, this should either be 'configmap' or 'secret'"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Warnf(msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	opts, less, ok := eh.listParamsFor(w, r)
+	if !ok {
 		return
 	}
 	switch component {
 	case "configmap":
-		configMapList, err := eh.kubeClient.Core(eh.namespace).GetConfigMaps()
+		var configMapList *apiv1.ConfigMapList
+		var err error
+		if allNamespacesRequested(r) {
+			configMapList, err = eh.allNamespacesConfigMaps(r, opts)
+		} else {
+			configMapList, err = eh.core(r).GetConfigMapsContext(r.Context(), opts)
+		}
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			RespondWithAPIError(w, r, err)
 			return
 		}
+		sortItems(configMapList.Items, func(i int) *metav1.ObjectMeta { return &configMapList.Items[i].ObjectMeta }, less)
 		RespondWithJSON(w, http.StatusOK, &configMapList)
 	case "secret":
-		secretList, err := eh.kubeClient.Core(eh.namespace).GetSecrets()
+		reveal, ok := revealAllowed(w, r)
+		if !ok {
+			return
+		}
+		var secretList *apiv1.SecretList
+		var err error
+		if allNamespacesRequested(r) {
+			secretList, err = eh.allNamespacesSecrets(r, opts)
+		} else {
+			secretList, err = eh.core(r).GetSecretsContext(r.Context(), opts)
+		}
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			RespondWithAPIError(w, r, err)
 			return
 		}
-		RespondWithJSON(w, http.StatusOK, &secretList)
+		sortItems(secretList.Items, func(i int) *metav1.ObjectMeta { return &secretList.Items[i].ObjectMeta }, less)
+		if reveal {
+			names := make([]string, 0, len(secretList.Items))
+			for _, secret := range secretList.Items {
+				names = append(names, secret.Namespace+"/"+secret.Name)
+			}
+			auditReveal(r, eh.namespaceFor(r), names)
+			RespondWithJSON(w, http.StatusOK, &secretList)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, redactSecretList(secretList))
 	default:
 		msg := "Resource doesn't exist"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Infof(msg)
+		logFor(r).Infof(msg)
 		return
 	}
 }
 
 func (eh *WebAPIHandler) newCoreHandler(w http.ResponseWriter, r *http.Request) {
-	zap.S().Debugf("POST /core/{component}")
+	logFor(r).Debugf("POST /core/{component}")
 	vars := mux.Vars(r)
 	component, ok := vars["component"]
 	if !ok {
 		msg := "No component was specified in the request, this should either be 'configmap' or 'secret'"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Warnf(msg)
+		logFor(r).Warnf(msg)
 		return
 	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if component == "configmap" || component == "secret" {
+		if isGenericBody(body) {
+			eh.saveGenericCore(w, r, component, body, "")
+			return
+		}
+	}
 	switch component {
 	case "configmap":
 		cfgmap := ConfigMapBody{}
-		err := json.NewDecoder(r.Body).Decode(&cfgmap)
+		err = json.Unmarshal(body, &cfgmap)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			RespondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 		var result *apiv1.ConfigMap
-		result, err = eh.kubeClient.Core(eh.namespace).CreateConfigMap(cfgmap.ProjectName, cfgmap.OrgID, cfgmap.BaseURL)
+		result, err = eh.core(r).CreateConfigMapContext(r.Context(), cfgmap.ProjectName, cfgmap.OrgID, cfgmap.BaseURL)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			RespondWithAPIError(w, r, err)
 			return
 		}
 		RespondWithJSON(w, http.StatusOK, &result)
 	case "secret":
 		secret := SecretBody{}
-		err := json.NewDecoder(r.Body).Decode(&secret)
+		err = json.Unmarshal(body, &secret)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			RespondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 		var result *apiv1.Secret
-		result, err = eh.kubeClient.Core(eh.namespace).CreateSecret(secret.SecretName, secret.ApiUser, secret.ApiKey)
+		result, err = eh.core(r).CreateSecretContext(r.Context(), secret.SecretName, secret.ApiUser, secret.ApiKey)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			RespondWithAPIError(w, r, err)
 			return
 		}
-		RespondWithJSON(w, http.StatusOK, &result)
+		RespondWithJSON(w, http.StatusOK, redactSecret(result))
 	default:
 		msg := "Resource doesn't exist"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Infof(msg)
+		logFor(r).Infof(msg)
 		return
 	}
 }
 
+func (eh *WebAPIHandler) updateCoreHandler(w http.ResponseWriter, r *http.Request) {
+	logFor(r).Debugf("PUT /core/{component}/{name}")
+	vars := mux.Vars(r)
+	component, ok := vars["component"]
+	if !ok {
+		msg := "No component was specified in the request, this should either be 'configmap' or 'secret'"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	name, ok := vars["name"]
+	if !ok {
+		msg := "No name was specified in the request"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch component {
+	case "configmap":
+		// The Ops Manager project body is only accepted on create.
+		if !isGenericBody(body) {
+			msg := "A ConfigMap update needs a data field"
+			RespondWithError(w, http.StatusBadRequest, msg)
+			logFor(r).Infof(msg)
+			return
+		}
+		eh.saveGenericCore(w, r, component, body, name)
+	case "secret":
+		if isGenericBody(body) {
+			eh.saveGenericCore(w, r, component, body, name)
+			return
+		}
+		eh.rotateCredentials(w, r, name, body)
+	default:
+		msg := "Resource doesn't exist"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Infof(msg)
+		return
+	}
+}
+
+// isGenericBody tells the generic ConfigMap and Secret bodies, which carry
+// their content in data or stringData, apart from the Ops Manager ones.
+func isGenericBody(body []byte) bool {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return false
+	}
+	_, hasData := fields["data"]
+	_, hasStringData := fields["stringData"]
+	return hasData || hasStringData
+}
+
+// saveGenericCore creates, or updates when name is set, a ConfigMap or Secret
+// from a generic body.
+func (eh *WebAPIHandler) saveGenericCore(w http.ResponseWriter, r *http.Request, component string, body []byte, name string) {
+	switch component {
+	case "configmap":
+		cfgmap := GenericConfigMapBody{}
+		err := json.Unmarshal(body, &cfgmap)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if name != "" && cfgmap.Name != "" && cfgmap.Name != name {
+			RespondWithError(w, http.StatusBadRequest, "The name in the body doesn't match the one in the request path")
+			return
+		}
+		spec := clientv1.ConfigMapSpec{
+			Name:            cfgmap.Name,
+			Labels:          cfgmap.Labels,
+			Annotations:     cfgmap.Annotations,
+			Data:            cfgmap.Data,
+			ResourceVersion: cfgmap.ResourceVersion,
+		}
+		var result *apiv1.ConfigMap
+		if name == "" {
+			result, err = eh.core(r).CreateGenericConfigMapContext(r.Context(), spec)
+		} else {
+			spec.Name = name
+			result, err = eh.core(r).UpdateGenericConfigMapContext(r.Context(), spec)
+		}
+		if err != nil {
+			RespondWithAPIError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, &result)
+	case "secret":
+		secret := GenericSecretBody{}
+		err := json.Unmarshal(body, &secret)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if name != "" && secret.Name != "" && secret.Name != name {
+			RespondWithError(w, http.StatusBadRequest, "The name in the body doesn't match the one in the request path")
+			return
+		}
+		spec := clientv1.SecretSpec{
+			Name:            secret.Name,
+			Type:            secret.Type,
+			Labels:          secret.Labels,
+			Annotations:     secret.Annotations,
+			Data:            secret.Data,
+			StringData:      secret.StringData,
+			ResourceVersion: secret.ResourceVersion,
+		}
+		var result *apiv1.Secret
+		if name == "" {
+			result, err = eh.core(r).CreateGenericSecretContext(r.Context(), spec)
+		} else {
+			spec.Name = name
+			result, err = eh.core(r).UpdateGenericSecretContext(r.Context(), spec)
+		}
+		if err != nil {
+			RespondWithAPIError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, redactSecret(result))
+	}
+}
+
 func (eh *WebAPIHandler) deleteCoreHandler(w http.ResponseWriter, r *http.Request) {
-	zap.S().Debugf("DELETE /core/{component}/{name}")
+	logFor(r).Debugf("DELETE /core/{component}/{name}")
 	vars := mux.Vars(r)
 	component, ok := vars["component"]
 	if !ok {
 		msg := "No component was specified in the request, this should either be 'configmap' or 'secret'"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Warnf(msg)
+		logFor(r).Warnf(msg)
 		return
 	}
 	name, ok := vars["name"]
 	if !ok {
 		msg := "No name was specified in the request"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Warnf(msg)
+		logFor(r).Warnf(msg)
 		return
 	}
 	switch component {
 	case "configmap":
-		err := eh.kubeClient.Core(eh.namespace).DeleteConfigMap(name)
+		err := eh.core(r).DeleteConfigMapContext(r.Context(), name)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			RespondWithAPIError(w, r, err)
 			return
 		}
 		RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 	case "secret":
-		err := eh.kubeClient.Core(eh.namespace).DeleteSecret(name)
+		err := eh.core(r).DeleteSecretContext(r.Context(), name)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			RespondWithAPIError(w, r, err)
 			return
 		}
 		RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 	default:
 		msg := "Resource doesn't exist"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Infof(msg)
+		logFor(r).Infof(msg)
+		return
+	}
+}
+
+type RotationResult struct {
+	Secret     RedactedSecret    `json:"secret"`
+	Reconciled []string          `json:"reconciled"`
+	Failed     map[string]string `json:"failed,omitempty"`
+}
+
+// rotateCredentials replaces the API user and key of a credentials Secret.
+// With ?reconcile=true every MongoDB resource using the Secret is annotated
+// with the rotation time so the operator reconciles it with the new key.
+func (eh *WebAPIHandler) rotateCredentials(w http.ResponseWriter, r *http.Request, name string, body []byte) {
+	credentials := CredentialsBody{}
+	err := json.Unmarshal(body, &credentials)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if credentials.ApiUser == "" || credentials.ApiKey == "" {
+		RespondWithError(w, http.StatusBadRequest, "Both apiUser and apiKey are required to rotate credentials")
+		return
+	}
+	secret, err := eh.core(r).RotateCredentialsContext(r.Context(), name, credentials.ApiUser, credentials.ApiKey, credentials.ResourceVersion)
+	if err != nil {
+		RespondWithAPIError(w, r, err)
 		return
 	}
+	result := RotationResult{Secret: redactSecret(secret), Reconciled: []string{}}
+	if !boolQuery(r, "reconcile") {
+		RespondWithJSON(w, http.StatusOK, &result)
+		return
+	}
+
+	mongodbs, err := eh.mongoDBs(r).ListContext(r.Context(), metav1.ListOptions{})
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				clientv1.CredentialsRotatedAnnotation: secret.Annotations[clientv1.CredentialsRotatedAnnotation],
+			},
+		},
+	})
+	for _, mongodb := range mongodbs.Items {
+		if mongodb.Spec.Credentials != name {
+			continue
+		}
+		_, err = eh.mongoDBs(r).PatchContext(r.Context(), mongodb.Name, types.MergePatchType, patch)
+		if err != nil {
+			logFor(r).Warnf("Failed to annotate MongoDB %s after credentials rotation: %s", mongodb.Name, err.Error())
+			if result.Failed == nil {
+				result.Failed = map[string]string{}
+			}
+			result.Failed[mongodb.Name] = err.Error()
+			continue
+		}
+		result.Reconciled = append(result.Reconciled, mongodb.Name)
+	}
+	RespondWithJSON(w, http.StatusOK, &result)
 }
 
 func InitialiseCoreRoutes(r *mux.Router, handler *WebAPIHandler) {
@@ -194,5 +465,6 @@ func InitialiseCoreRoutes(r *mux.Router, handler *WebAPIHandler) {
 	corerouter.Methods("GET").Path("/{name}").HandlerFunc(handler.findCoreHandler)
 	corerouter.Methods("GET").Path("").HandlerFunc(handler.allCoreHandler)
 	corerouter.Methods("POST").Path("").HandlerFunc(handler.newCoreHandler)
+	corerouter.Methods("PUT").Path("/{name}").HandlerFunc(handler.updateCoreHandler)
 	corerouter.Methods("DELETE").Path("/{name}").HandlerFunc(handler.deleteCoreHandler)
 }
@@ -0,0 +1,90 @@
+package webapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrorBody is returned by every endpoint on failure. Reason, Details and
+// Code are only set when the failure was reported by the Kubernetes API.
+type ErrorBody struct {
+	Error   string                `json:"error"`
+	Reason  metav1.StatusReason   `json:"reason,omitempty"`
+	Details *metav1.StatusDetails `json:"details,omitempty"`
+	Code    int32                 `json:"code,omitempty"`
+}
+
+// reasonStatusCodes maps the reasons used by the Kubernetes API to the HTTP
+// status codes returned by the web API.
+var reasonStatusCodes = map[metav1.StatusReason]int{
+	metav1.StatusReasonNotFound:              http.StatusNotFound,
+	metav1.StatusReasonAlreadyExists:         http.StatusConflict,
+	metav1.StatusReasonConflict:              http.StatusConflict,
+	metav1.StatusReasonInvalid:               http.StatusUnprocessableEntity,
+	metav1.StatusReasonForbidden:             http.StatusForbidden,
+	metav1.StatusReasonUnauthorized:          http.StatusUnauthorized,
+	metav1.StatusReasonBadRequest:            http.StatusBadRequest,
+	metav1.StatusReasonTooManyRequests:       http.StatusTooManyRequests,
+	metav1.StatusReasonServiceUnavailable:    http.StatusServiceUnavailable,
+	metav1.StatusReasonServerTimeout:         http.StatusServiceUnavailable,
+	metav1.StatusReasonTimeout:               http.StatusGatewayTimeout,
+	metav1.StatusReasonGone:                  http.StatusGone,
+	metav1.StatusReasonExpired:               http.StatusGone,
+	metav1.StatusReasonMethodNotAllowed:      http.StatusMethodNotAllowed,
+	metav1.StatusReasonUnsupportedMediaType:  http.StatusUnsupportedMediaType,
+	metav1.StatusReasonNotAcceptable:         http.StatusNotAcceptable,
+	metav1.StatusReasonRequestEntityTooLarge: http.StatusRequestEntityTooLarge,
+}
+
+// StatusCodeForError returns the HTTP status code matching an error returned
+// by the clientset. A call that ran out of time is reported as 504 Gateway
+// Timeout, other errors that don't come from the Kubernetes API as 500
+// Internal Server Error.
+func StatusCodeForError(err error) int {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return http.StatusGatewayTimeout
+		}
+		return http.StatusInternalServerError
+	}
+	if code, ok := reasonStatusCodes[status.Status().Reason]; ok {
+		return code
+	}
+	if code := int(status.Status().Code); code >= http.StatusBadRequest {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// errorBodyFor builds the error body for err, carrying over the reason,
+// details and code of Kubernetes API errors.
+func errorBodyFor(err error) ErrorBody {
+	body := ErrorBody{Error: err.Error()}
+	if status, ok := err.(apierrors.APIStatus); ok {
+		body.Reason = status.Status().Reason
+		body.Details = status.Status().Details
+		body.Code = status.Status().Code
+	}
+	return body
+}
+
+// RespondWithAPIError translates an error returned by the clientset into the
+// matching HTTP status code and error body.
+func RespondWithAPIError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil && errors.Is(err, r.Context().Err()) {
+		logFor(r).Debugf("Kubernetes API request abandoned, the client went away: %s", err.Error())
+		return
+	}
+	code := StatusCodeForError(err)
+	if code >= http.StatusInternalServerError {
+		logFor(r).Errorf("Kubernetes API request failed: %s", err.Error())
+	} else {
+		logFor(r).Debugf("Kubernetes API request rejected: %s", err.Error())
+	}
+	RespondWithJSON(w, code, errorBodyFor(err))
+}
@@ -0,0 +1,120 @@
+package webapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+	"github.com/10gen/dredd/metrics"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// heartbeatInterval is how often a comment line is written to an idle event
+// stream so that proxies and browsers don't close the connection.
+const heartbeatInterval = 15 * time.Second
+
+// streamMongoDBEvents watches the MongoDB resources matching opts and writes
+// every event to w as a server-sent event until the client goes away or the
+// watch is closed by the API server. The resourceVersion query parameter, or
+// the Last-Event-ID header sent by reconnecting browsers, resumes the stream.
+func (eh *WebAPIHandler) streamMongoDBEvents(w http.ResponseWriter, r *http.Request, opts metav1.ListOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		msg := "Streaming is not supported by the connection"
+		RespondWithError(w, http.StatusInternalServerError, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	opts.ResourceVersion = r.URL.Query().Get("resourceVersion")
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		opts.ResourceVersion = lastEventID
+	}
+	watcher, err := eh.mongoDBs(r).WatchContext(r.Context(), opts)
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
+	defer watcher.Stop()
+	metrics.WatchStreamsInFlight.Inc()
+	defer metrics.WatchStreamsInFlight.Dec()
+
+	// Event streams outlive the server's write timeout by design.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			logFor(r).Debugf("Client disconnected, closing MongoDB event stream")
+			return
+		case <-eh.shutdown:
+			logFor(r).Debugf("Server shutting down, closing MongoDB event stream")
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				logFor(r).Debugf("MongoDB watch closed by the API server")
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				logFor(r).Debugf("Failed to write MongoDB event: %s", err.Error())
+				return
+			}
+			flusher.Flush()
+			if event.Type == watch.Error {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent serialises a single watch event in the text/event-stream format.
+// The resourceVersion of the object is used as the event id.
+func writeEvent(w http.ResponseWriter, event watch.Event) error {
+	data, err := json.Marshal(event.Object)
+	if err != nil {
+		return err
+	}
+	if mongodb, ok := event.Object.(*typesv1.MongoDB); ok && mongodb.ResourceVersion != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", mongodb.ResourceVersion); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+func (eh *WebAPIHandler) eventsMongoDBHandler(w http.ResponseWriter, r *http.Request) {
+	logFor(r).Debugf("GET /mongodbs/{name}/events")
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		msg := "No MongoDB deployment name was specified in the request"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	opts := metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String(),
+	}
+	eh.streamMongoDBEvents(w, r, opts)
+}
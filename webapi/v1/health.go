@@ -0,0 +1,81 @@
+package webapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type CheckResult struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type ReadinessResult struct {
+	Ready  bool          `json:"ready"`
+	Checks []CheckResult `json:"checks"`
+}
+
+func (eh *WebAPIHandler) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readinessTimeout bounds the checks of a readiness probe together, so a slow
+// API server fails the probe instead of piling up blocked requests.
+const readinessTimeout = 5 * time.Second
+
+// readyzHandler reports ready only when the API server answers, the MongoDB
+// CRD is registered and the default namespace exists.
+func (eh *WebAPIHandler) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	cluster := eh.kubeClient.Cluster()
+	result := ReadinessResult{Ready: true}
+	check := func(name string, run func() (string, error)) {
+		message, err := run()
+		if err != nil {
+			result.Ready = false
+			result.Checks = append(result.Checks, CheckResult{Name: name, OK: false, Message: err.Error()})
+			return
+		}
+		result.Checks = append(result.Checks, CheckResult{Name: name, OK: true, Message: message})
+	}
+
+	check("apiserver", func() (string, error) {
+		info, err := cluster.ServerVersionContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		return "Kubernetes " + info.GitVersion, nil
+	})
+	check("crd", func() (string, error) {
+		resource, err := cluster.MongoDBResourceContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		return "resource " + resource.Name + " is registered", nil
+	})
+	check("namespace", func() (string, error) {
+		namespace, err := cluster.GetNamespaceContext(ctx, eh.settings().Namespace)
+		if err != nil {
+			return "", err
+		}
+		return "namespace " + namespace.Name + " exists", nil
+	})
+
+	code := http.StatusOK
+	if !result.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	RespondWithJSON(w, code, &result)
+}
+
+// InitialiseHealthRoutes registers the probes, which are served without
+// authentication so the kubelet can reach them.
+func InitialiseHealthRoutes(r *mux.Router, handler *WebAPIHandler) {
+	r.Methods("GET").Path("/healthz").HandlerFunc(handler.healthzHandler)
+	r.Methods("GET").Path("/readyz").HandlerFunc(handler.readyzHandler)
+}
@@ -0,0 +1,180 @@
+package webapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// listOptionsFor reads the labelSelector, fieldSelector, limit and continue
+// query parameters of a list request. The selectors are checked here so a
+// typo is reported as a bad request rather than an API server error.
+func listOptionsFor(r *http.Request) (metav1.ListOptions, error) {
+	query := r.URL.Query()
+	opts := metav1.ListOptions{
+		LabelSelector: query.Get("labelSelector"),
+		FieldSelector: query.Get("fieldSelector"),
+		Continue:      query.Get("continue"),
+	}
+	if _, err := labels.Parse(opts.LabelSelector); err != nil {
+		return opts, fmt.Errorf("invalid labelSelector: %s", err.Error())
+	}
+	if _, err := fields.ParseSelector(opts.FieldSelector); err != nil {
+		return opts, fmt.Errorf("invalid fieldSelector: %s", err.Error())
+	}
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || value < 1 {
+			return opts, fmt.Errorf("invalid limit %q, expected a positive number", limit)
+		}
+		opts.Limit = value
+	}
+	return opts, nil
+}
+
+func paginated(opts metav1.ListOptions) bool {
+	return opts.Limit > 0 || opts.Continue != ""
+}
+
+// listParamsFor reads the list options and sort order of a list request,
+// responding with 400 Bad Request when they are invalid. A continue token
+// can't span the separate lists merged for an allNamespaces request, so
+// pagination is only allowed there when every namespace is allowed. Sorting
+// happens here rather than in the API server, so it can't be combined with
+// pagination, which would only order each page.
+func (eh *WebAPIHandler) listParamsFor(w http.ResponseWriter, r *http.Request) (metav1.ListOptions, objectLess, bool) {
+	opts, err := listOptionsFor(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return opts, nil, false
+	}
+	if paginated(opts) && allNamespacesRequested(r) && !eh.anyNamespaceAllowed() {
+		RespondWithError(w, http.StatusBadRequest, "Pagination with allNamespaces requires every namespace to be allowed")
+		return opts, nil, false
+	}
+	less, err := sortOrderFor(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return opts, nil, false
+	}
+	if less != nil && paginated(opts) {
+		RespondWithError(w, http.StatusBadRequest, "Sorting can't be combined with limit or continue")
+		return opts, nil, false
+	}
+	return opts, less, true
+}
+
+// objectLess orders two objects of a list.
+type objectLess func(a *metav1.ObjectMeta, b *metav1.ObjectMeta) bool
+
+// sortOrderFor reads the sort query parameter: name or creationTimestamp,
+// prefixed with - for descending order. Without it the order of the API
+// server, by namespace and name, is kept.
+func sortOrderFor(r *http.Request) (objectLess, error) {
+	key := r.URL.Query().Get("sort")
+	descending := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+
+	var less objectLess
+	switch key {
+	case "":
+		return nil, nil
+	case "name":
+		less = nameLess
+	case "creationTimestamp":
+		less = func(a *metav1.ObjectMeta, b *metav1.ObjectMeta) bool {
+			if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+				return a.CreationTimestamp.Before(&b.CreationTimestamp)
+			}
+			return nameLess(a, b)
+		}
+	default:
+		return nil, fmt.Errorf("invalid sort %q, expected name or creationTimestamp, optionally prefixed with -", key)
+	}
+	if descending {
+		return func(a *metav1.ObjectMeta, b *metav1.ObjectMeta) bool { return less(b, a) }, nil
+	}
+	return less, nil
+}
+
+func nameLess(a *metav1.ObjectMeta, b *metav1.ObjectMeta) bool {
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// sortItems sorts the items slice of a list, meta returning the metadata of
+// its i-th item, unless no order was requested.
+func sortItems(items interface{}, meta func(i int) *metav1.ObjectMeta, less objectLess) {
+	if less == nil {
+		return
+	}
+	sort.SliceStable(items, func(i int, j int) bool {
+		return less(meta(i), meta(j))
+	})
+}
+
+// mongoSpecFilters are the MongoSpec fields the MongoDB list can be filtered
+// on, by query parameter.
+var mongoSpecFilters = []struct {
+	param string
+	value func(spec *typesv1.MongoSpec) string
+}{
+	{"type", func(spec *typesv1.MongoSpec) string { return spec.Type }},
+	{"version", func(spec *typesv1.MongoSpec) string { return spec.Version }},
+	{"project", func(spec *typesv1.MongoSpec) string { return spec.Project }},
+	{"credentials", func(spec *typesv1.MongoSpec) string { return spec.Credentials }},
+}
+
+// mongoSpecFilterFor builds the filter matching the MongoSpec query
+// parameters. A version also matches its patch releases and variants, so
+// version=4.0 matches 4.0.9 and 4.0.9-ent. The filter runs on the server
+// after listing, so a page can hold fewer items than the limit.
+func mongoSpecFilterFor(r *http.Request) (func(spec *typesv1.MongoSpec) bool, error) {
+	query := r.URL.Query()
+	if mongoDBType := query.Get("type"); mongoDBType != "" {
+		switch mongoDBType {
+		case typesv1.Standalone, typesv1.ReplicaSet, typesv1.ShardedCluster:
+		default:
+			return nil, fmt.Errorf("invalid type %q, expected %s, %s or %s",
+				mongoDBType, typesv1.Standalone, typesv1.ReplicaSet, typesv1.ShardedCluster)
+		}
+	}
+	return func(spec *typesv1.MongoSpec) bool {
+		for _, filter := range mongoSpecFilters {
+			wanted := query.Get(filter.param)
+			if wanted == "" {
+				continue
+			}
+			value := filter.value(spec)
+			if filter.param == "version" {
+				if value != wanted && !strings.HasPrefix(value, wanted+".") && !strings.HasPrefix(value, wanted+"-") {
+					return false
+				}
+			} else if value != wanted {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// filterMongoDBs keeps the MongoDB resources whose spec matches.
+func filterMongoDBs(list *typesv1.MongoDBList, match func(spec *typesv1.MongoSpec) bool) {
+	kept := list.Items[:0]
+	for _, mongodb := range list.Items {
+		if match(&mongodb.Spec) {
+			kept = append(kept, mongodb)
+		}
+	}
+	list.Items = kept
+}
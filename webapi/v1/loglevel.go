@@ -0,0 +1,30 @@
+package webapi
+
+import (
+	"net/http"
+
+	"github.com/10gen/dredd/logging"
+)
+
+// logLevelHandler serves the level of the server's logger. zap.AtomicLevel
+// answers GET with {"level":"info"} and changes the level on PUT with the
+// same body. Only an authenticated caller, checked by authMiddleware for the
+// "put" verb on /loglevel, may change it.
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		logging.Level.ServeHTTP(w, r)
+		return
+	}
+	user, authenticated := userFor(r)
+	if !authenticated {
+		msg := "Changing the log level requires authentication to be enabled"
+		RespondWithError(w, http.StatusForbidden, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	before := logging.Level.Level()
+	logging.Level.ServeHTTP(w, r)
+	if after := logging.Level.Level(); after != before {
+		logFor(r).Infof("Log level changed from %s to %s by %q", before, after, user.Username)
+	}
+}
@@ -0,0 +1,111 @@
+package webapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+	"github.com/10gen/dredd/metrics"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// statusRecorder remembers the status code and size of a response. It keeps
+// implementing http.Flusher so event streams still work behind it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.status = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// routeTemplate returns the path template of the matched route, so metrics
+// aren't labelled with every MongoDB or Secret name.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		route := routeTemplate(r)
+		code := strconv.Itoa(recorder.status)
+		metrics.HTTPRequestsTotal.WithLabelValues(route, r.Method, code).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, r.Method, code).Observe(time.Since(start).Seconds())
+	})
+}
+
+var mongoDBResourcesDesc = prometheus.NewDesc(
+	"gokube_mongodb_resources",
+	"Number of MongoDB resources in the default namespace, by type and phase.",
+	[]string{"namespace", "type", "phase"}, nil,
+)
+
+// mongoDBCollector counts the MongoDB resources in the default namespace
+// every time the metrics are scraped.
+type mongoDBCollector struct {
+	handler *WebAPIHandler
+}
+
+func (c *mongoDBCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mongoDBResourcesDesc
+}
+
+func (c *mongoDBCollector) Collect(ch chan<- prometheus.Metric) {
+	namespace := c.handler.settings().Namespace
+	mongodbs, err := c.handler.kubeClient.MongoDBs(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		zap.S().Warnf("Failed to list MongoDB resources for metrics: %s", err.Error())
+		ch <- prometheus.NewInvalidMetric(mongoDBResourcesDesc, err)
+		return
+	}
+	type key struct {
+		deploymentType string
+		phase          typesv1.Phase
+	}
+	counts := map[key]int{}
+	for _, mongodb := range mongodbs.Items {
+		counts[key{mongodb.Spec.Type, mongodb.Status.Phase}]++
+	}
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(mongoDBResourcesDesc, prometheus.GaugeValue, float64(count), namespace, k.deploymentType, string(k.phase))
+	}
+}
@@ -2,63 +2,397 @@ package webapi
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"strings"
+	"time"
 
+	clientv1 "github.com/10gen/dredd/clientset/v1"
 	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+	"github.com/10gen/dredd/crdapi/validation"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/gorilla/mux"
-	"go.uber.org/zap"
 )
 
 func (eh *WebAPIHandler) findMongoDBHandler(w http.ResponseWriter, r *http.Request) {
-	zap.S().Debugf("GET /mongodbs/{name}")
+	logFor(r).Debugf("GET /mongodbs/{name}")
 	vars := mux.Vars(r)
 	name, ok := vars["name"]
 	if !ok {
 		msg := "No MongoDB deployment name was specified in the request"
 		RespondWithError(w, http.StatusBadRequest, msg)
-		zap.S().Warnf(msg)
+		logFor(r).Warnf(msg)
 		return
 	}
-	mongodb, err := eh.kubeClient.MongoDBs(eh.namespace).Get(name, metav1.GetOptions{})
+	mongodb, err := eh.mongoDBs(r).GetContext(r.Context(), name, metav1.GetOptions{})
 	if err != nil {
-		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		RespondWithAPIError(w, r, err)
 		return
 	}
 	RespondWithJSON(w, http.StatusOK, &mongodb)
 }
 
+func (eh *WebAPIHandler) statusMongoDBHandler(w http.ResponseWriter, r *http.Request) {
+	logFor(r).Debugf("GET /mongodbs/{name}/status")
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		msg := "No MongoDB deployment name was specified in the request"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	mongodb, err := eh.mongoDBs(r).GetStatusContext(r.Context(), name)
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, &mongodb.Status)
+}
+
 func (eh *WebAPIHandler) allMongoDBHandler(w http.ResponseWriter, r *http.Request) {
-	zap.S().Debugf("GET /mongodbs")
-	mongodbs, err := eh.kubeClient.MongoDBs(eh.namespace).List(metav1.ListOptions{})
+	logFor(r).Debugf("GET /mongodbs")
+	opts, less, ok := eh.listParamsFor(w, r)
+	if !ok {
+		return
+	}
+	if boolQuery(r, "watch") {
+		eh.streamMongoDBEvents(w, r, metav1.ListOptions{
+			LabelSelector: opts.LabelSelector,
+			FieldSelector: opts.FieldSelector,
+		})
+		return
+	}
+	match, err := mongoSpecFilterFor(r)
 	if err != nil {
-		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var mongodbs *typesv1.MongoDBList
+	if allNamespacesRequested(r) {
+		mongodbs, err = eh.allNamespacesMongoDBs(r, opts)
+	} else {
+		mongodbs, err = eh.mongoDBs(r).ListContext(r.Context(), opts)
+	}
+	if err != nil {
+		RespondWithAPIError(w, r, err)
 		return
 	}
+	filterMongoDBs(mongodbs, match)
+	sortItems(mongodbs.Items, func(i int) *metav1.ObjectMeta { return &mongodbs.Items[i].ObjectMeta }, less)
 	RespondWithJSON(w, http.StatusOK, &mongodbs)
 }
 
 func (eh *WebAPIHandler) newMongoDBHandler(w http.ResponseWriter, r *http.Request) {
-	zap.S().Debugf("POST /mongodbs")
+	logFor(r).Debugf("POST /mongodbs")
 	mongodb := typesv1.MongoDB{}
 	err := json.NewDecoder(r.Body).Decode(&mongodb)
 	if err != nil {
-		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	typesv1.SetMongoSpecDefaults(&mongodb.Spec, eh.settings().Defaults)
+	if !eh.validMongoDB(w, r, &mongodb) {
 		return
 	}
+	waitPhase := typesv1.Phase(r.URL.Query().Get("wait"))
+	if waitPhase != "" && !knownPhase(waitPhase) {
+		msg := fmt.Sprintf("Invalid wait phase %q, expected %s, %s, %s or %s", waitPhase,
+			typesv1.PhasePending, typesv1.PhaseReconciling, typesv1.PhaseRunning, typesv1.PhaseFailed)
+		RespondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	waitTimeout := defaultWaitTimeout
+	if t := r.URL.Query().Get("timeout"); t != "" {
+		waitTimeout, err = time.ParseDuration(t)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid timeout: "+err.Error())
+			return
+		}
+		if waitTimeout <= 0 {
+			RespondWithError(w, http.StatusBadRequest, "Invalid timeout: "+t+" is not a positive duration")
+			return
+		}
+	}
 	var result *typesv1.MongoDB
-	result, err = eh.kubeClient.MongoDBs(eh.namespace).Create(&mongodb)
+	result, err = eh.mongoDBs(r).CreateContext(r.Context(), &mongodb)
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
+	if waitPhase == "" {
+		RespondWithJSON(w, http.StatusOK, &result)
+		return
+	}
+	logFor(r).Debugf("Waiting up to %s for MongoDB %s to reach phase %s", waitTimeout, result.Name, waitPhase)
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(waitTimeout + waitWriteMargin))
+	ctx, cancel := eh.untilShutdown(r)
+	defer cancel()
+	var last *typesv1.MongoDB
+	last, err = eh.mongoDBs(r).WaitForPhaseContext(ctx, result.Name, waitPhase, waitTimeout)
+	switch {
+	case err == nil:
+		RespondWithJSON(w, http.StatusOK, &last)
+	case err == clientv1.ErrWaitTimeout:
+		respondWithLastStatus(w, http.StatusGatewayTimeout, err.Error(), last)
+	case err == clientv1.ErrPhaseFailed, err == clientv1.ErrResourceDeleted:
+		respondWithLastStatus(w, http.StatusInternalServerError, err.Error(), last)
+	case ctx.Err() != nil && r.Context().Err() == nil:
+		// The MongoDB was created, only the wait was cut short.
+		msg := fmt.Sprintf("Server shutting down before MongoDB %s reached phase %s", result.Name, waitPhase)
+		respondWithLastStatus(w, http.StatusServiceUnavailable, msg, last)
+	default:
+		RespondWithAPIError(w, r, err)
+	}
+}
+
+// validMongoDB validates a MongoDB resource before it is submitted,
+// responding with the field errors, or with the API error that prevented
+// checking its references.
+func (eh *WebAPIHandler) validMongoDB(w http.ResponseWriter, r *http.Request, mongodb *typesv1.MongoDB) bool {
+	errs, err := validation.ValidateMongoDB(r.Context(), mongodb, eh.core(r))
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return false
+	}
+	if len(errs) > 0 {
+		RespondWithValidationErrors(w, errs)
+		return false
+	}
+	return true
+}
+
+// knownPhase reports whether phase is one the operator reports, so a typo in
+// ?wait= is rejected instead of waiting for the whole timeout.
+func knownPhase(phase typesv1.Phase) bool {
+	switch phase {
+	case typesv1.PhasePending, typesv1.PhaseReconciling, typesv1.PhaseRunning, typesv1.PhaseFailed:
+		return true
+	}
+	return false
+}
+
+// defaultWaitTimeout bounds POST /mongodbs?wait=<phase> when no timeout is
+// given in the request.
+const defaultWaitTimeout = 10 * time.Minute
+
+// waitWriteMargin extends the write deadline of a waiting request past the
+// wait timeout, so the last observed status can still be written.
+const waitWriteMargin = 10 * time.Second
+
+func respondWithLastStatus(w http.ResponseWriter, code int, message string, last *typesv1.MongoDB) {
+	body := map[string]interface{}{"error": message}
+	if last != nil {
+		body["status"] = last.Status
+	}
+	RespondWithJSON(w, code, body)
+}
+
+func (eh *WebAPIHandler) updateMongoDBHandler(w http.ResponseWriter, r *http.Request) {
+	logFor(r).Debugf("PUT /mongodbs/{name}")
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		msg := "No MongoDB deployment name was specified in the request"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	mongodb := typesv1.MongoDB{}
+	err := json.NewDecoder(r.Body).Decode(&mongodb)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if mongodb.Name == "" {
+		mongodb.Name = name
+	}
+	if mongodb.Name != name {
+		msg := "The MongoDB deployment name in the body doesn't match the one in the request path"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	typesv1.SetMongoSpecDefaults(&mongodb.Spec, eh.settings().Defaults)
+	if !eh.validMongoDB(w, r, &mongodb) {
+		return
+	}
+	var result *typesv1.MongoDB
+	result, err = eh.mongoDBs(r).UpdateContext(r.Context(), &mongodb)
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, &result)
+}
+
+func (eh *WebAPIHandler) patchMongoDBHandler(w http.ResponseWriter, r *http.Request) {
+	logFor(r).Debugf("PATCH /mongodbs/{name}")
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		msg := "No MongoDB deployment name was specified in the request"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	pt, ok := patchTypeFor(r.Header.Get("Content-Type"))
+	if !ok {
+		msg := "Unsupported patch type, use application/merge-patch+json or application/json-patch+json"
+		RespondWithError(w, http.StatusUnsupportedMediaType, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mongodbs := eh.mongoDBs(r)
+	current, err := mongodbs.GetRawContext(r.Context(), name)
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
+	mongodb, err := applyPatch(current, pt, data)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid patch: "+err.Error())
+		return
+	}
+	if mongodb.Name != name {
+		msg := "The patch can't change the MongoDB deployment name"
+		RespondWithError(w, http.StatusBadRequest, msg)
+		logFor(r).Warnf(msg)
+		return
+	}
+	patched, err := json.Marshal(mongodb)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	typesv1.SetMongoSpecDefaults(&mongodb.Spec, eh.settings().Defaults)
+	if !eh.validMongoDB(w, r, mongodb) {
+		return
+	}
+	data, err = completePatch(pt, data, patched, mongodb)
 	if err != nil {
 		RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	var result *typesv1.MongoDB
+	result, err = mongodbs.PatchContext(r.Context(), name, pt, data)
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
 	RespondWithJSON(w, http.StatusOK, &result)
 }
 
+// applyPatch applies a JSON merge patch or a JSON patch to the stored JSON of
+// the MongoDB resource locally, so the result can be defaulted and validated
+// like the body of a PUT. Decoding drops the fields MongoSpec doesn't model,
+// which is why the caller's patch, not this result, is sent to the API server.
+func applyPatch(original []byte, pt types.PatchType, patch []byte) (*typesv1.MongoDB, error) {
+	var patched []byte
+	var err error
+	switch pt {
+	case types.JSONPatchType:
+		var operations jsonpatch.Patch
+		operations, err = jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, err
+		}
+		patched, err = operations.Apply(original)
+	default:
+		patched, err = jsonpatch.MergePatch(original, patch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &typesv1.MongoDB{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// completePatch extends the caller's patch with the fields defaulting changed
+// in the patched resource, and with its resourceVersion: the one read before
+// patching, unless the patch sets its own. The API server then rejects the
+// patch with a Conflict if the resource changed since it was validated.
+func completePatch(pt types.PatchType, patch []byte, patched []byte, defaulted *typesv1.MongoDB) ([]byte, error) {
+	result, err := json.Marshal(defaulted)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := jsonpatch.CreateMergePatch(patched, result)
+	if err != nil {
+		return nil, err
+	}
+	extra := map[string]interface{}{}
+	if err := json.Unmarshal(defaults, &extra); err != nil {
+		return nil, err
+	}
+	extra["metadata"] = map[string]interface{}{"resourceVersion": defaulted.ResourceVersion}
+
+	if pt == types.JSONPatchType {
+		operations := []interface{}{}
+		if err := json.Unmarshal(patch, &operations); err != nil {
+			return nil, err
+		}
+		return json.Marshal(append(operations, addOperations("", extra)...))
+	}
+	extraPatch, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.MergeMergePatches(patch, extraPatch)
+}
+
+// addOperations turns the leaves of a merge patch into JSON patch add
+// operations, which also replace existing values.
+func addOperations(prefix string, fields map[string]interface{}) []interface{} {
+	operations := []interface{}{}
+	for key, value := range fields {
+		path := prefix + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			operations = append(operations, addOperations(path, nested)...)
+			continue
+		}
+		operations = append(operations, map[string]interface{}{"op": "add", "path": path, "value": value})
+	}
+	return operations
+}
+
+// patchTypeFor maps the Content-Type of a PATCH request to the Kubernetes
+// patch type. Plain JSON is treated as a JSON merge patch. Strategic merge
+// patches are not supported by the API server for custom resources.
+func patchTypeFor(contentType string) (types.PatchType, bool) {
+	if contentType == "" {
+		return types.MergePatchType, true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	switch mediaType {
+	case "application/json", string(types.MergePatchType):
+		return types.MergePatchType, true
+	case string(types.JSONPatchType):
+		return types.JSONPatchType, true
+	}
+	return "", false
+}
+
 func (eh *WebAPIHandler) deleteMongoDBHandler(w http.ResponseWriter, r *http.Request) {
-	zap.S().Debugf("DELETE /mongodbs/{name}")
+	logFor(r).Debugf("DELETE /mongodbs/{name}")
 	vars := mux.Vars(r)
 	name, ok := vars["name"]
 	if !ok {
@@ -66,18 +400,122 @@ func (eh *WebAPIHandler) deleteMongoDBHandler(w http.ResponseWriter, r *http.Req
 		RespondWithError(w, http.StatusBadRequest, msg)
 		return
 	}
-	err := eh.kubeClient.MongoDBs(eh.namespace).Delete(name)
+	if boolQuery(r, "cascade") {
+		eh.cascadeDeleteMongoDB(w, r, name)
+		return
+	}
+	err := eh.mongoDBs(r).DeleteContext(r.Context(), name)
 	if err != nil {
-		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		RespondWithAPIError(w, r, err)
 		return
 	}
 	RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
 
+type CascadeObject struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type CascadeResult struct {
+	Result  string          `json:"result"`
+	Removed []CascadeObject `json:"removed"`
+	Kept    []CascadeObject `json:"kept"`
+}
+
+// cascadeDeleteMongoDB deletes the MongoDB resource and then its project
+// ConfigMap and credentials Secret, unless another MongoDB resource in the
+// namespace still references them or they are the configured defaults, which
+// later resources rely on.
+func (eh *WebAPIHandler) cascadeDeleteMongoDB(w http.ResponseWriter, r *http.Request, name string) {
+	mongodbs := eh.mongoDBs(r)
+	core := eh.core(r)
+	mongodb, err := mongodbs.GetContext(r.Context(), name, metav1.GetOptions{})
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
+	err = mongodbs.DeleteContext(r.Context(), name)
+	if err != nil {
+		RespondWithAPIError(w, r, err)
+		return
+	}
+	// Once the MongoDB resource is gone its references are cleaned up even
+	// if the caller disconnects, so they are not bound to the request.
+	result := CascadeResult{Result: "success", Removed: []CascadeObject{}, Kept: []CascadeObject{}}
+	result.Removed = append(result.Removed, CascadeObject{Kind: "MongoDB", Name: name})
+
+	others, err := mongodbs.List(metav1.ListOptions{})
+	if err != nil {
+		body := errorBodyFor(err)
+		body.Error = "MongoDB resource deleted but its references could not be checked: " + body.Error
+		RespondWithJSON(w, StatusCodeForError(err), body)
+		return
+	}
+	projectUsers, credentialsUsers := []string{}, []string{}
+	for _, other := range others.Items {
+		if other.Name == name {
+			continue
+		}
+		if other.Spec.Project == mongodb.Spec.Project {
+			projectUsers = append(projectUsers, other.Name)
+		}
+		if other.Spec.Credentials == mongodb.Spec.Credentials {
+			credentialsUsers = append(credentialsUsers, other.Name)
+		}
+	}
+
+	defaults := eh.settings().Defaults
+	references := []struct {
+		kind         string
+		name         string
+		users        []string
+		defaultValue string
+		delete       func(string) error
+	}{
+		{"ConfigMap", mongodb.Spec.Project, projectUsers, defaults.Project, core.DeleteConfigMap},
+		{"Secret", mongodb.Spec.Credentials, credentialsUsers, defaults.Credentials, core.DeleteSecret},
+	}
+	for _, ref := range references {
+		if ref.name == "" {
+			continue
+		}
+		object := CascadeObject{Kind: ref.kind, Name: ref.name}
+		if len(ref.users) > 0 {
+			object.Reason = "still referenced by " + strings.Join(ref.users, ", ")
+			result.Kept = append(result.Kept, object)
+			continue
+		}
+		if ref.name == ref.defaultValue {
+			object.Reason = "configured as namespace default"
+			result.Kept = append(result.Kept, object)
+			continue
+		}
+		err = ref.delete(ref.name)
+		switch {
+		case err == nil:
+			result.Removed = append(result.Removed, object)
+		case apierrors.IsNotFound(err):
+			object.Reason = "not found"
+			result.Kept = append(result.Kept, object)
+		default:
+			logFor(r).Warnf("Failed to delete %s %s: %s", ref.kind, ref.name, err.Error())
+			object.Reason = err.Error()
+			result.Kept = append(result.Kept, object)
+		}
+	}
+	RespondWithJSON(w, http.StatusOK, &result)
+}
+
 func InitialiseMongoDBRoutes(r *mux.Router, handler *WebAPIHandler) {
 	mongodbsrouter := r.PathPrefix("/mongodbs").Subrouter()
 	mongodbsrouter.Methods("GET").Path("/{name}").HandlerFunc(handler.findMongoDBHandler)
+	mongodbsrouter.Methods("GET").Path("/{name}/status").HandlerFunc(handler.statusMongoDBHandler)
+	mongodbsrouter.Methods("GET").Path("/{name}/events").HandlerFunc(handler.eventsMongoDBHandler)
 	mongodbsrouter.Methods("GET").Path("").HandlerFunc(handler.allMongoDBHandler)
 	mongodbsrouter.Methods("POST").Path("").HandlerFunc(handler.newMongoDBHandler)
+	mongodbsrouter.Methods("PUT").Path("/{name}").HandlerFunc(handler.updateMongoDBHandler)
+	mongodbsrouter.Methods("PATCH").Path("/{name}").HandlerFunc(handler.patchMongoDBHandler)
 	mongodbsrouter.Methods("DELETE").Path("/{name}").HandlerFunc(handler.deleteMongoDBHandler)
 }
@@ -0,0 +1,164 @@
+package webapi
+
+import (
+	"net/http"
+
+	clientv1 "github.com/10gen/dredd/clientset/v1"
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+
+	"github.com/gorilla/mux"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnyNamespace in the allowed namespaces lets the server touch every
+// namespace in the cluster.
+const AnyNamespace = "*"
+
+// namespaceFor returns the namespace of a namespaced route, or the default
+// namespace for the routes kept as aliases at the root of the API.
+func (eh *WebAPIHandler) namespaceFor(r *http.Request) string {
+	if ns, ok := mux.Vars(r)["ns"]; ok {
+		return ns
+	}
+	return eh.settings().Namespace
+}
+
+func (eh *WebAPIHandler) mongoDBs(r *http.Request) clientv1.MongoDBInterface {
+	return eh.clientFor(r).MongoDBs(eh.namespaceFor(r))
+}
+
+func (eh *WebAPIHandler) core(r *http.Request) clientv1.CoreInterface {
+	return eh.clientFor(r).Core(eh.namespaceFor(r))
+}
+
+func (eh *WebAPIHandler) anyNamespaceAllowed() bool {
+	for _, ns := range eh.settings().AllowedNamespaces {
+		if ns == AnyNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceAllowed reports whether ns is the default namespace or is in the
+// allow-list from the configuration.
+func (eh *WebAPIHandler) namespaceAllowed(ns string) bool {
+	settings := eh.settings()
+	if ns == settings.Namespace || eh.anyNamespaceAllowed() {
+		return true
+	}
+	for _, allowed := range settings.AllowedNamespaces {
+		if ns == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// listedNamespaces returns the namespaces visited by an all-namespaces
+// listing when the allow-list doesn't permit a cluster-wide one. An empty
+// namespace would make that listing cluster-wide, so it is never included.
+func (eh *WebAPIHandler) listedNamespaces() []string {
+	settings := eh.settings()
+	namespaces := []string{}
+	for _, ns := range append([]string{settings.Namespace}, settings.AllowedNamespaces...) {
+		if ns != "" && !containsString(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceMiddleware rejects requests to namespaced routes targeting a
+// namespace outside the allow-list.
+func (eh *WebAPIHandler) namespaceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := mux.Vars(r)["ns"]
+		if !eh.namespaceAllowed(ns) {
+			msg := "Namespace " + ns + " is not in the list of allowed namespaces"
+			RespondWithError(w, http.StatusForbidden, msg)
+			logFor(r).Warnf(msg)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func allNamespacesRequested(r *http.Request) bool {
+	return boolQuery(r, "allNamespaces")
+}
+
+func (eh *WebAPIHandler) allNamespacesMongoDBs(r *http.Request, opts metav1.ListOptions) (*typesv1.MongoDBList, error) {
+	if eh.anyNamespaceAllowed() {
+		return eh.clientFor(r).MongoDBs(metav1.NamespaceAll).ListContext(r.Context(), opts)
+	}
+	result := &typesv1.MongoDBList{Items: []typesv1.MongoDB{}}
+	for _, ns := range eh.listedNamespaces() {
+		list, err := eh.clientFor(r).MongoDBs(ns).ListContext(r.Context(), opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, list.Items...)
+	}
+	return result, nil
+}
+
+func (eh *WebAPIHandler) allNamespacesConfigMaps(r *http.Request, opts metav1.ListOptions) (*apiv1.ConfigMapList, error) {
+	if eh.anyNamespaceAllowed() {
+		return eh.clientFor(r).Core(metav1.NamespaceAll).GetConfigMapsContext(r.Context(), opts)
+	}
+	result := &apiv1.ConfigMapList{Items: []apiv1.ConfigMap{}}
+	for _, ns := range eh.listedNamespaces() {
+		list, err := eh.clientFor(r).Core(ns).GetConfigMapsContext(r.Context(), opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, list.Items...)
+	}
+	return result, nil
+}
+
+func (eh *WebAPIHandler) allNamespacesSecrets(r *http.Request, opts metav1.ListOptions) (*apiv1.SecretList, error) {
+	if eh.anyNamespaceAllowed() {
+		return eh.clientFor(r).Core(metav1.NamespaceAll).GetSecretsContext(r.Context(), opts)
+	}
+	result := &apiv1.SecretList{Items: []apiv1.Secret{}}
+	for _, ns := range eh.listedNamespaces() {
+		list, err := eh.clientFor(r).Core(ns).GetSecretsContext(r.Context(), opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, list.Items...)
+	}
+	return result, nil
+}
+
+func (eh *WebAPIHandler) allowedNamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	logFor(r).Debugf("GET /namespaces")
+	RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"default": eh.settings().Namespace,
+		"allowed": eh.listedNamespaces(),
+		"any":     eh.anyNamespaceAllowed(),
+	})
+}
+
+// InitialiseNamespacedRoutes registers every route a second time under
+// /namespaces/{ns}, guarded by the namespace allow-list.
+func InitialiseNamespacedRoutes(r *mux.Router, handler *WebAPIHandler) {
+	r.Methods("GET").Path("/namespaces").HandlerFunc(handler.allowedNamespacesHandler)
+	nsrouter := r.PathPrefix("/namespaces/{ns}").Subrouter()
+	nsrouter.Use(handler.namespaceMiddleware)
+	InitialiseMongoDBRoutes(nsrouter, handler)
+	InitialiseCoreRoutes(nsrouter, handler)
+	InitialiseProjectRoutes(nsrouter, handler)
+}
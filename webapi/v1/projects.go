@@ -0,0 +1,113 @@
+package webapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
+	"github.com/10gen/dredd/crdapi/validation"
+
+	"github.com/gorilla/mux"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type ProjectBody struct {
+	Name        string            `json:"name"`
+	Project     ConfigMapBody     `json:"project"`
+	Credentials SecretBody        `json:"credentials"`
+	Spec        typesv1.MongoSpec `json:"spec"`
+}
+
+type ProjectResult struct {
+	ConfigMap *apiv1.ConfigMap `json:"configMap"`
+	Secret    *RedactedSecret  `json:"secret"`
+	MongoDB   *typesv1.MongoDB `json:"mongodb"`
+}
+
+type RollbackErrorBody struct {
+	ErrorBody
+	RolledBack     bool     `json:"rolledBack"`
+	RollbackErrors []string `json:"rollbackErrors"`
+}
+
+func (eh *WebAPIHandler) newProjectHandler(w http.ResponseWriter, r *http.Request) {
+	logFor(r).Debugf("POST /projects")
+	body := ProjectBody{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mongodb := typesv1.MongoDB{
+		ObjectMeta: metav1.ObjectMeta{Name: body.Name},
+		Spec:       body.Spec,
+	}
+	mongodb.Spec.Project = body.Project.ProjectName
+	mongodb.Spec.Credentials = body.Credentials.SecretName
+	typesv1.SetMongoSpecDefaults(&mongodb.Spec, eh.settings().Defaults)
+	errs := validation.ValidateMongoSpec(&mongodb.Spec, field.NewPath("spec"))
+	if body.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("name"), "name of the MongoDB resource"))
+	}
+	if len(errs) > 0 {
+		RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	core := eh.core(r)
+	result := ProjectResult{}
+	// The rollback steps don't use the request context, so they still run
+	// when the failure is the caller going away.
+	var rollback []func() error
+
+	result.ConfigMap, err = core.CreateConfigMapContext(r.Context(), body.Project.ProjectName, body.Project.OrgID, body.Project.BaseURL)
+	if err != nil {
+		respondWithRollback(w, r, "Failed to create the project ConfigMap", err, rollback)
+		return
+	}
+	rollback = append(rollback, func() error { return core.DeleteConfigMap(body.Project.ProjectName) })
+
+	secret, err := core.CreateSecretContext(r.Context(), body.Credentials.SecretName, body.Credentials.ApiUser, body.Credentials.ApiKey)
+	if err != nil {
+		respondWithRollback(w, r, "Failed to create the credentials Secret", err, rollback)
+		return
+	}
+	redacted := redactSecret(secret)
+	result.Secret = &redacted
+	rollback = append(rollback, func() error { return core.DeleteSecret(body.Credentials.SecretName) })
+
+	result.MongoDB, err = eh.mongoDBs(r).CreateContext(r.Context(), &mongodb)
+	if err != nil {
+		respondWithRollback(w, r, "Failed to create the MongoDB resource", err, rollback)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, &result)
+}
+
+// respondWithRollback undoes the steps that already succeeded, newest first,
+// and reports the original failure together with any rollback failure.
+func respondWithRollback(w http.ResponseWriter, r *http.Request, message string, cause error, rollback []func() error) {
+	logFor(r).Warnf("%s, rolling back %d created object(s): %s", message, len(rollback), cause.Error())
+	rollbackErrors := []string{}
+	for i := len(rollback) - 1; i >= 0; i-- {
+		if err := rollback[i](); err != nil {
+			logFor(r).Errorf("Rollback failed: %s", err.Error())
+			rollbackErrors = append(rollbackErrors, err.Error())
+		}
+	}
+	body := RollbackErrorBody{
+		ErrorBody:      errorBodyFor(cause),
+		RolledBack:     len(rollbackErrors) == 0,
+		RollbackErrors: rollbackErrors,
+	}
+	body.Error = message + ": " + body.Error
+	RespondWithJSON(w, StatusCodeForError(cause), &body)
+}
+
+func InitialiseProjectRoutes(r *mux.Router, handler *WebAPIHandler) {
+	projectsrouter := r.PathPrefix("/projects").Subrouter()
+	projectsrouter.Methods("POST").Path("").HandlerFunc(handler.newProjectHandler)
+}
@@ -0,0 +1,64 @@
+package webapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	clientv1 "github.com/10gen/dredd/clientset/v1"
+
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the ID correlating a web request with its log lines
+// and the Kubernetes calls made for it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the IDs accepted from callers.
+const maxRequestIDLength = 128
+
+// validRequestID accepts printable ASCII without spaces, so a caller's ID can
+// go into log lines and the user agent as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// requestIDMiddleware keeps the caller's X-Request-ID, or generates one, and
+// returns it with the response. The ID goes into the request context, from
+// which the KubeClient propagates it to the API server.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(clientv1.ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+func requestIDFor(r *http.Request) string {
+	return clientv1.RequestIDFrom(r.Context())
+}
+
+// logFor returns the logger for lines about a request, tagged with its ID.
+func logFor(r *http.Request) *zap.SugaredLogger {
+	if id := requestIDFor(r); id != "" {
+		return zap.S().With("requestID", id)
+	}
+	return zap.S()
+}
@@ -0,0 +1,115 @@
+package webapi
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/10gen/dredd/logging"
+
+	"go.uber.org/zap"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lastAppliedAnnotation holds the full object, data included, when a Secret
+// was created with kubectl apply.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// RedactedSecret is returned instead of a Secret unless its data is
+// explicitly revealed. Keys maps every data key to the HMAC-SHA256 of its
+// value, so callers can tell whether a value changed without seeing it.
+// Hashes are keyed per server process: they compare within one process only,
+// and can't be checked offline against guessed values.
+type RedactedSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Type              apiv1.SecretType  `json:"type,omitempty"`
+	Keys              map[string]string `json:"keys"`
+}
+
+type RedactedSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RedactedSecret `json:"items"`
+}
+
+func redactSecret(secret *apiv1.Secret) RedactedSecret {
+	redacted := RedactedSecret{
+		TypeMeta:   secret.TypeMeta,
+		ObjectMeta: *secret.ObjectMeta.DeepCopy(),
+		Type:       secret.Type,
+		Keys:       map[string]string{},
+	}
+	delete(redacted.Annotations, lastAppliedAnnotation)
+	for key, value := range secret.Data {
+		redacted.Keys[key] = redactedValue(value)
+	}
+	for key, value := range secret.StringData {
+		redacted.Keys[key] = redactedValue([]byte(value))
+	}
+	return redacted
+}
+
+// redactionKey keys the hashes of redacted values, random for each process.
+var redactionKey = newRedactionKey()
+
+func newRedactionKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func redactedValue(value []byte) string {
+	mac := hmac.New(sha256.New, redactionKey)
+	mac.Write(value)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func redactSecretList(secrets *apiv1.SecretList) RedactedSecretList {
+	redacted := RedactedSecretList{
+		TypeMeta: secrets.TypeMeta,
+		ListMeta: secrets.ListMeta,
+		Items:    make([]RedactedSecret, 0, len(secrets.Items)),
+	}
+	for i := range secrets.Items {
+		redacted.Items = append(redacted.Items, redactSecret(&secrets.Items[i]))
+	}
+	return redacted
+}
+
+// revealAllowed reports whether the request may see secret data. Revealing
+// needs ?reveal=true and an authenticated caller; the auth middleware has
+// already checked the caller's permission on the secrets/reveal subresource.
+func revealAllowed(w http.ResponseWriter, r *http.Request) (reveal bool, ok bool) {
+	if !boolQuery(r, "reveal") {
+		return false, true
+	}
+	if _, authenticated := userFor(r); !authenticated {
+		msg := "Revealing secret data requires authentication to be enabled"
+		RespondWithError(w, http.StatusForbidden, msg)
+		logFor(r).Warnf(msg)
+		return false, false
+	}
+	return true, true
+}
+
+// auditReveal records who was shown the data of which secrets, through the
+// audit logger so the record is kept whatever the log level.
+func auditReveal(r *http.Request, namespace string, names []string) {
+	user, _ := userFor(r)
+	logging.Audit().Info("Secret data revealed",
+		zap.String("user", user.Username),
+		zap.Strings("groups", user.Groups),
+		zap.String("namespace", namespace),
+		zap.Strings("secrets", names),
+		zap.String("remoteAddr", r.RemoteAddr),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("requestID", requestIDFor(r)),
+	)
+}
@@ -0,0 +1,178 @@
+package webapi
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TLSOptions enables HTTPS on the API listener. Setting ClientCAFile also
+// verifies client certificates, making the listener mutual TLS.
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	// VerifyClientCertIfGiven accepts clients without a certificate, for
+	// example the kubelet probing /healthz, but still verifies the ones
+	// that present one.
+	VerifyClientCertIfGiven bool
+	MinVersion              uint16
+	// ReloadInterval is how often the files are checked for changes, such
+	// as a cert-manager renewal of a mounted Secret.
+	ReloadInterval time.Duration
+}
+
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion converts a version such as "1.2" to its crypto/tls value.
+// An empty version defaults to TLS 1.2.
+func ParseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return tls.VersionTLS12, nil
+	}
+	if v, ok := tlsVersions[version]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, use one of 1.0, 1.1, 1.2 or 1.3", version)
+}
+
+// certReloader serves the certificate and client CAs last read from disk and
+// reloads them whenever the content of one of the files changes.
+type certReloader struct {
+	opts TLSOptions
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	contents  [][]byte
+}
+
+func newCertReloader(opts TLSOptions) (*certReloader, error) {
+	cr := &certReloader{opts: opts}
+	if _, err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) files() []string {
+	files := []string{cr.opts.CertFile, cr.opts.KeyFile}
+	if cr.opts.ClientCAFile != "" {
+		files = append(files, cr.opts.ClientCAFile)
+	}
+	return files
+}
+
+// reload reads the files again and swaps in the new certificate and client
+// CAs if anything changed. It reports whether a swap happened.
+func (cr *certReloader) reload() (bool, error) {
+	contents := make([][]byte, 0, 3)
+	for _, file := range cr.files() {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		contents = append(contents, content)
+	}
+
+	cr.mu.RLock()
+	unchanged := len(cr.contents) == len(contents)
+	for i := 0; unchanged && i < len(contents); i++ {
+		unchanged = bytes.Equal(cr.contents[i], contents[i])
+	}
+	cr.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return false, err
+	}
+	var clientCAs *x509.CertPool
+	if cr.opts.ClientCAFile != "" {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(contents[2]) {
+			return false, fmt.Errorf("no certificate found in client CA file %s", cr.opts.ClientCAFile)
+		}
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.cert = &cert
+	cr.clientCAs = clientCAs
+	cr.contents = contents
+	return true, nil
+}
+
+// watch checks the files every ReloadInterval until stop is closed. A file
+// that fails to load keeps the previous certificate in use.
+func (cr *certReloader) watch(stop <-chan struct{}) {
+	if cr.opts.ReloadInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(cr.opts.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reloaded, err := cr.reload()
+			if err != nil {
+				zap.S().Errorf("Failed to reload TLS certificates, keeping the current ones: %s", err.Error())
+				continue
+			}
+			if reloaded {
+				zap.S().Infof("Reloaded TLS certificates from %s", cr.opts.CertFile)
+			}
+		}
+	}
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// tlsConfig builds the listener configuration. With a client CA every
+// handshake gets a configuration holding the current CA pool, so rotated CAs
+// apply to new connections straight away.
+func (cr *certReloader) tlsConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion:     cr.opts.MinVersion,
+		GetCertificate: cr.getCertificate,
+	}
+	if cr.opts.ClientCAFile == "" {
+		return config
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	if cr.opts.VerifyClientCertIfGiven {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cr.mu.RLock()
+		defer cr.mu.RUnlock()
+		clientConfig := config.Clone()
+		clientConfig.GetConfigForClient = nil
+		clientConfig.ClientCAs = cr.clientCAs
+		return clientConfig, nil
+	}
+	return config
+}
@@ -1,22 +1,108 @@
 package webapi
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	clientv1 "github.com/10gen/dredd/clientset/v1"
+	typesv1 "github.com/10gen/dredd/crdapi/types/v1"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 type WebAPIHandler struct {
 	kubeClient *clientv1.KubeClient
-	namespace  string
+	// current holds the *Settings in use, swapped as a whole on reload.
+	current atomic.Value
+	// shutdown is closed when the server stops, ending every event stream
+	// and wait.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	// reviews caches the TokenReviews and SubjectAccessReviews of callers.
+	reviews *authCache
+	// clients caches the KubeClients impersonating callers.
+	clients *cache.LRUExpireCache
+}
+
+// Options configures the web API served by ServeAPI.
+type Options struct {
+	Settings
+	// Authentication requires a bearer token on every request, authorizes
+	// it with a SubjectAccessReview and impersonates the caller.
+	Authentication bool
+	Server         ServerOptions
+	// Updates delivers new Settings while the server runs, for example
+	// after the configuration file was edited.
+	Updates <-chan Settings
+}
+
+// Settings are the options that can change without restarting the server.
+// Requests already in flight keep the settings they started with.
+type Settings struct {
+	// Namespace is used by the routes that don't name a namespace.
+	Namespace string
+	// AllowedNamespaces lists the other namespaces that can be reached
+	// through /namespaces/{ns}. AnyNamespace allows all of them.
+	AllowedNamespaces []string
+	Defaults          typesv1.SpecDefaults
+	StaticTokens      []StaticToken
+}
+
+type ServerOptions struct {
+	Address             string
+	ReadTimeout         time.Duration
+	WriteTimeout        time.Duration
+	IdleTimeout         time.Duration
+	MaxHeaderBytes      int
+	ShutdownGracePeriod time.Duration
+	TLS                 TLSOptions
 }
 
 func RespondWithError(w http.ResponseWriter, code int, message string) {
-	RespondWithJSON(w, code, map[string]string{"error": message})
+	RespondWithJSON(w, code, ErrorBody{Error: message})
+}
+
+type FieldError struct {
+	Field    string      `json:"field"`
+	Type     string      `json:"type"`
+	BadValue interface{} `json:"value,omitempty"`
+	Detail   string      `json:"detail,omitempty"`
 }
+
+// RespondWithValidationErrors reports every field error found while
+// validating a request body with a 422 Unprocessable Entity.
+func RespondWithValidationErrors(w http.ResponseWriter, errs field.ErrorList) {
+	fieldErrors := make([]FieldError, 0, len(errs))
+	for _, err := range errs {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:    err.Field,
+			Type:     string(err.Type),
+			BadValue: err.BadValue,
+			Detail:   err.Detail,
+		})
+	}
+	RespondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":       errs.ToAggregate().Error(),
+		"fieldErrors": fieldErrors,
+	})
+}
+
+// boolQuery reports whether the query parameter is set to a true value.
+func boolQuery(r *http.Request, name string) bool {
+	value, _ := strconv.ParseBool(r.URL.Query().Get(name))
+	return value
+}
+
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, _ := json.Marshal(payload)
 	w.Header().Set("Content-Type", "application/json")
@@ -24,13 +110,132 @@ func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(response)
 }
 
-func ServeAPI(endpoint string, clientSet *clientv1.KubeClient, namespace string) error {
+// ServeAPI serves the web API until ctx is cancelled, then stops accepting
+// connections, ends the event streams and waits, and gives in-flight requests the
+// configured grace period to finish.
+func ServeAPI(ctx context.Context, clientSet *clientv1.KubeClient, opts Options) error {
 	handler := &WebAPIHandler{
 		kubeClient: clientSet,
-		namespace:  namespace,
+		shutdown:   make(chan struct{}),
+		reviews:    newAuthCache(),
+		clients:    cache.NewLRUExpireCache(impersonatingClientCacheSize),
 	}
+	handler.current.Store(&opts.Settings)
+	go handler.applyUpdates(ctx, opts.Updates)
+	prometheus.MustRegister(&mongoDBCollector{handler: handler})
 	router := mux.NewRouter()
-	InitialiseMongoDBRoutes(router, handler)
-	InitialiseCoreRoutes(router, handler)
-	return http.ListenAndServe(endpoint, router)
+	router.Use(requestIDMiddleware, accessLogMiddleware)
+	router.NotFoundHandler = unmatched(http.NotFoundHandler())
+	router.MethodNotAllowedHandler = unmatched(http.HandlerFunc(methodNotAllowed))
+	router.Methods("GET").Path("/metrics").Handler(promhttp.Handler())
+	InitialiseHealthRoutes(router, handler)
+
+	apirouter := router.PathPrefix("/").Subrouter()
+	apirouter.Use(metricsMiddleware)
+	if opts.Authentication {
+		apirouter.Use(handler.authMiddleware)
+	}
+	InitialiseMongoDBRoutes(apirouter, handler)
+	InitialiseCoreRoutes(apirouter, handler)
+	InitialiseProjectRoutes(apirouter, handler)
+	InitialiseNamespacedRoutes(apirouter, handler)
+	apirouter.Methods("GET", "PUT").Path("/loglevel").HandlerFunc(logLevelHandler)
+
+	server := &http.Server{
+		Addr:           opts.Server.Address,
+		Handler:        router,
+		ReadTimeout:    opts.Server.ReadTimeout,
+		WriteTimeout:   opts.Server.WriteTimeout,
+		IdleTimeout:    opts.Server.IdleTimeout,
+		MaxHeaderBytes: opts.Server.MaxHeaderBytes,
+	}
+	server.RegisterOnShutdown(handler.closeStreams)
+
+	serveErr := make(chan error, 1)
+	if opts.Server.TLS.Enabled() {
+		certs, err := newCertReloader(opts.Server.TLS)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = certs.tlsConfig()
+		go certs.watch(ctx.Done())
+		go func() {
+			serveErr <- server.ListenAndServeTLS("", "")
+		}()
+	} else {
+		go func() {
+			serveErr <- server.ListenAndServe()
+		}()
+	}
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// A zero grace period disables the timeout, like the server's others.
+	shutdownCtx := context.Background()
+	if grace := opts.Server.ShutdownGracePeriod; grace > 0 {
+		zap.S().Infof("Shutting down, waiting up to %s for in-flight requests", grace)
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, grace)
+		defer cancel()
+	} else {
+		zap.S().Infof("Shutting down, waiting for in-flight requests")
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return err
+	}
+	return nil
+}
+
+// unmatched wraps the handler of requests matching no route in the router's
+// middlewares, which mux only runs for matched routes.
+func unmatched(handler http.Handler) http.Handler {
+	return requestIDMiddleware(accessLogMiddleware(handler))
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+func (eh *WebAPIHandler) settings() *Settings {
+	return eh.current.Load().(*Settings)
+}
+
+func (eh *WebAPIHandler) applyUpdates(ctx context.Context, updates <-chan Settings) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case settings, ok := <-updates:
+			if !ok {
+				return
+			}
+			eh.current.Store(&settings)
+			zap.S().Infof("Web API settings updated, default namespace is %q", settings.Namespace)
+		}
+	}
+}
+
+func (eh *WebAPIHandler) closeStreams() {
+	eh.shutdownOnce.Do(func() {
+		close(eh.shutdown)
+	})
+}
+
+// untilShutdown derives a context from the request that is also cancelled
+// when the server shuts down, for handlers that can outlast the shutdown
+// grace period.
+func (eh *WebAPIHandler) untilShutdown(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+	go func() {
+		select {
+		case <-eh.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
 }